@@ -0,0 +1,69 @@
+package hedging
+
+import (
+	"math"
+
+	"golang.org/x/text/message"
+)
+
+// Weight of the raw beta in Blume adjustment toward the market beta of 1
+const blumeWeight = 0.67
+
+// ////////////////////////////////////////////////////////
+// Blume-adjusted beta: betas tend to regress to 1 over time
+// ////////////////////////////////////////////////////////
+func blumeBeta(beta float64) float64 {
+	return blumeWeight*beta + (1 - blumeWeight)
+}
+
+// ////////////////////////////////////////////////////////
+// Vasicek-adjusted betas: Bayesian shrinkage of each beta
+// toward the cross-sectional mean of the batch, the more
+// uncertain the estimate is, the stronger it is shrunk.
+// Result is NaN if the batch is too small to estimate the
+// cross-sectional variance or beta has no standard error
+// ////////////////////////////////////////////////////////
+func vasicekBetas(stats []Regression) []float64 {
+	result := make([]float64, len(stats))
+	if len(stats) < 2 {
+		for idx := range result {
+			result[idx] = math.NaN()
+		}
+		return result
+	}
+
+	var mean, variance float64
+	for _, item := range stats {
+		mean += item.Beta / float64(len(stats))
+	}
+	for _, item := range stats {
+		variance += (item.Beta - mean) * (item.Beta - mean) / float64(len(stats)-1)
+	}
+
+	for idx, item := range stats {
+		errorVariance := item.BetaStdErr * item.BetaStdErr
+		if math.IsNaN(errorVariance) || variance+errorVariance == 0 {
+			result[idx] = math.NaN()
+			continue
+		}
+		result[idx] = (variance*item.Beta + errorVariance*mean) / (variance + errorVariance)
+	}
+	return result
+}
+
+// ////////////////////////////////////////////////////////
+// Print raw and adjusted betas of the batch as a table
+// ////////////////////////////////////////////////////////
+func printAdjustedBetas(printer *message.Printer, betas []betaReport) {
+	stats := make([]Regression, len(betas))
+	for idx, beta := range betas {
+		stats[idx] = beta.Regression
+	}
+	vasicek := vasicekBetas(stats)
+
+	printer.Printf("Adjusted betas:\n")
+	printer.Printf("\t%-12s %10s %10s %10s\n", "Asset", "Raw", "Blume", "Vasicek")
+	for idx, beta := range betas {
+		printer.Printf("\t%-12s %10f %10f %10f\n", beta.asset, beta.Beta, blumeBeta(beta.Beta), vasicek[idx])
+	}
+}
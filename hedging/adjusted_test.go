@@ -0,0 +1,41 @@
+package hedging
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlumeBeta(t *testing.T) {
+	assert.InDelta(t, 1.0, blumeBeta(1), 1e-12)
+	assert.InDelta(t, 1.67, blumeBeta(2), 1e-12)
+	assert.InDelta(t, 0.33, blumeBeta(0), 1e-12)
+}
+
+func TestVasicekBetas(t *testing.T) {
+	stats := []Regression{
+		{Beta: 0.5, BetaStdErr: 0.1},
+		{Beta: 1.5, BetaStdErr: 0.5},
+		{Beta: 1.0, BetaStdErr: 0},
+	}
+	result := vasicekBetas(stats)
+
+	// Cross-sectional mean is 1 and variance is 0.25
+	assert.InDelta(t, (0.25*0.5+0.01*1)/(0.25+0.01), result[0], 1e-12)
+	assert.InDelta(t, (0.25*1.5+0.25*1)/(0.25+0.25), result[1], 1e-12)
+	assert.InDelta(t, 1.0, result[2], 1e-12)
+
+	// Noisy estimate is shrunk stronger than the precise one
+	assert.Less(t, math.Abs(result[1]-1), math.Abs(stats[1].Beta-1)*0.6)
+	assert.Greater(t, math.Abs(result[0]-1), math.Abs(stats[0].Beta-1)*0.9)
+}
+
+func TestVasicekBetasNeedBatch(t *testing.T) {
+	result := vasicekBetas([]Regression{{Beta: 1.2, BetaStdErr: 0.1}})
+	assert.True(t, math.IsNaN(result[0]))
+
+	result = vasicekBetas([]Regression{{Beta: 1.2, BetaStdErr: math.NaN()}, {Beta: 0.8, BetaStdErr: 0.1}})
+	assert.True(t, math.IsNaN(result[0]))
+	assert.False(t, math.IsNaN(result[1]))
+}
@@ -0,0 +1,263 @@
+package hedging
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+
+	"golang.org/x/text/message"
+	"gonum.org/v1/gonum/stat"
+)
+
+// Trailing window of the hedge ratio estimation and rebalancing period by default
+const (
+	DefaultBacktestWindow  = 120
+	DefaultRebalancePeriod = 5
+)
+
+type backtester struct {
+	cache Cache
+}
+
+// ////////////////////////////////////////////////////////
+// Constructor
+// ////////////////////////////////////////////////////////
+func newBacktester(cacheDSN string) (Executor, error) {
+	cache, err := NewCache(cacheDSN)
+	if err != nil {
+		return nil, err
+	}
+	return &backtester{cache: cache}, nil
+}
+
+// Out of sample period of the backtest
+type backtestStep struct {
+	date   string
+	asset  float64 // return of the asset
+	hedge  float64 // return of the hedge instrument
+	ratio  float64 // hedge ratio in force during the period
+	hedged float64 // return of the asset with the short hedge
+}
+
+// Hedge ratio set on the date
+type rebalancing struct {
+	date  string
+	ratio float64
+}
+
+// Walk forward history of the hedge
+type backtestResult struct {
+	steps      []backtestStep
+	rebalances []rebalancing
+	turnover   float64 // sum of absolute changes of the hedge ratio after the first one
+	outliers   int     // outliers treated in the estimation windows
+}
+
+// Performance of the return series
+type performance struct {
+	pnl        float64 // sum of returns, share of the position value
+	volatility float64 // annualized standard deviation of returns
+	drawdown   float64 // largest fall of cumulative P&L from its peak
+}
+
+// ////////////////////////////////////////////////////////
+// Command executor
+// ////////////////////////////////////////////////////////
+func (tester *backtester) Execute(command Command) error {
+	fmt.Printf("Backtest hedge of %s by %s\n", command.Asset, command.Hedge)
+
+	if len(command.Hedge) == 0 {
+		return fmt.Errorf("hedge asset was not specified. Run with -h for the help")
+	}
+	window, step, err := backtestPeriods(command)
+	if err != nil {
+		return err
+	}
+	if err = validateWeighting(command); err != nil {
+		return err
+	}
+	spec, err := newReturnSpec(command)
+	if err != nil {
+		return err
+	}
+	cleaning, err := newCleaningSpec(command)
+	if err != nil {
+		return err
+	}
+
+	resolver := newAssetResolver(tester.cache, command)
+	pair, err := fetchHedgePair(resolver, command)
+	if err != nil {
+		return err
+	}
+	asset, hedge := pair.asset, pair.hedge
+
+	dates, assetChanges, hedgeChanges := pairedReturns(asset, pair.assetHistory, hedge, pair.hedgeHistory, spec)
+	if len(assetChanges) <= window {
+		return fmt.Errorf("%d returns are not enough for the %d observations window", len(assetChanges), window)
+	}
+	fmt.Printf("Hedge ratio is estimated on %d trailing %s taken with %s and rebalanced every %d observations\n",
+		window, spec, describeWeighting(decayFactor(command)), step)
+
+	result := walkForward(dates, assetChanges, hedgeChanges, window, step, decayFactor(command), cleaning)
+	if cleaning.method != noOutliers {
+		fmt.Printf("%d outliers beyond %.1f (%s) treated in the estimation windows, out of sample returns are kept as is\n",
+			result.outliers, cleaning.limit, cleaning.method)
+	}
+
+	printer, err := GetPrinter()
+	if err != nil {
+		return err
+	}
+	printBacktest(printer, asset.Secid, hedge.Secid, result, spec.periodsPerYear())
+
+	if len(command.Output) > 0 {
+		if err = exportBacktest(command.Output, result); err != nil {
+			return fmt.Errorf("failed to export backtest: %s", err)
+		}
+		fmt.Printf("Backtest exported to %s\n", command.Output)
+	}
+	return nil
+}
+
+// ////////////////////////////////////////////////////////
+// Estimation window and rebalancing period of the backtest
+// ////////////////////////////////////////////////////////
+func backtestPeriods(command Command) (int, int, error) {
+	window, step := command.BacktestWindow, command.Rebalance
+	if window < 3 {
+		return 0, 0, fmt.Errorf("estimation window must contain at least 3 observations")
+	}
+	if step <= 0 {
+		return 0, 0, fmt.Errorf("rebalancing period must be positive")
+	}
+	return window, step, nil
+}
+
+// ////////////////////////////////////////////////////////
+// Re-estimate the minimum variance hedge ratio on the
+// trailing window every step observations and apply it to
+// the following returns only. Outliers are cleaned within
+// the trailing window, so later returns do not affect the
+// estimate and the hedge is evaluated on raw returns
+// ////////////////////////////////////////////////////////
+func walkForward(dates []string, asset []float64, hedge []float64, window int, step int, lambda float64,
+	cleaning cleaningSpec) backtestResult {
+	var result backtestResult
+	ratio := 0.0
+	for idx := window; idx < len(asset); idx++ {
+		if (idx-window)%step == 0 {
+			_, x, y, cleaned := cleanReturns(cleaning, dates[idx-window:idx], "hedge", hedge[idx-window:idx], "asset", asset[idx-window:idx])
+			for _, summary := range cleaned {
+				result.outliers += len(summary.dates)
+			}
+			estimate := weightedRegression(x, y, ewmaWeights(len(y), lambda)).Beta
+			if len(result.rebalances) > 0 {
+				result.turnover += math.Abs(estimate - ratio)
+			}
+			ratio = estimate
+			result.rebalances = append(result.rebalances, rebalancing{date: dates[idx-1], ratio: ratio})
+		}
+		result.steps = append(result.steps, backtestStep{
+			date:   dates[idx],
+			asset:  asset[idx],
+			hedge:  hedge[idx],
+			ratio:  ratio,
+			hedged: asset[idx] - ratio*hedge[idx],
+		})
+	}
+	return result
+}
+
+// ////////////////////////////////////////////////////////
+// P&L, volatility and drawdown of the returns
+// ////////////////////////////////////////////////////////
+func evaluate(returns []float64, periodsPerYear float64) performance {
+	var result performance
+	peak := 0.0
+	for _, value := range returns {
+		result.pnl += value
+		peak = math.Max(peak, result.pnl)
+		result.drawdown = math.Max(result.drawdown, peak-result.pnl)
+	}
+	result.volatility = stat.StdDev(returns, nil) * math.Sqrt(periodsPerYear)
+	return result
+}
+
+// Returns of the backtest without and with the hedge
+func (result backtestResult) returns() ([]float64, []float64) {
+	unhedged := make([]float64, len(result.steps))
+	hedged := make([]float64, len(result.steps))
+	for idx, step := range result.steps {
+		unhedged[idx], hedged[idx] = step.asset, step.hedged
+	}
+	return unhedged, hedged
+}
+
+// Share of the asset variance removed by the hedge out of sample
+func (result backtestResult) varianceReduction() float64 {
+	unhedged, hedged := result.returns()
+	return 1 - stat.Variance(hedged, nil)/stat.Variance(unhedged, nil)
+}
+
+// ////////////////////////////////////////////////////////
+// Print effectiveness of the hedge and its rebalancing
+// ////////////////////////////////////////////////////////
+func printBacktest(printer *message.Printer, asset string, hedge string, result backtestResult, periodsPerYear float64) {
+	unhedged, hedged := result.returns()
+	first, last := result.steps[0].date, result.steps[len(result.steps)-1].date
+	printer.Printf("Out of sample hedge of %s by %s from %s to %s, %d observations:\n", asset, hedge, first, last, len(result.steps))
+	printer.Printf("\t%-10s %10s %12s %14s\n", "Position", "P&L, %", "Volatility", "Max drawdown")
+	for _, row := range []struct {
+		label string
+		performance
+	}{{"Unhedged", evaluate(unhedged, periodsPerYear)}, {"Hedged", evaluate(hedged, periodsPerYear)}} {
+		printer.Printf("\t%-10s %10.2f %12f %14.2f\n", row.label, row.pnl*100, row.volatility, row.drawdown*100)
+	}
+	printer.Printf("\tVariance reduction %f\n", result.varianceReduction())
+
+	ratios := make([]float64, len(result.rebalances))
+	for idx, point := range result.rebalances {
+		ratios[idx] = point.ratio
+	}
+	printer.Printf("\t%d rebalances, turnover %f, average hedge ratio %f\n", len(result.rebalances), result.turnover, stat.Mean(ratios, nil))
+	printer.Printf("\t%-10s %12s\n", "Date", "Hedge ratio")
+	for _, point := range result.rebalances {
+		printer.Printf("\t%-10s %12f\n", point.date, point.ratio)
+	}
+}
+
+// ////////////////////////////////////////////////////////
+// Write returns and P&L of every period to CSV file
+// ////////////////////////////////////////////////////////
+func exportBacktest(filename string, result backtestResult) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	err = writer.Write([]string{"date", "asset_return", "hedge_return", "hedge_ratio", "hedged_return", "unhedged_pnl", "hedged_pnl"})
+	if err != nil {
+		return err
+	}
+	format := func(value float64) string {
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+	unhedgedPnl, hedgedPnl := 0.0, 0.0
+	for _, step := range result.steps {
+		unhedgedPnl += step.asset
+		hedgedPnl += step.hedged
+		err = writer.Write([]string{step.date, format(step.asset), format(step.hedge), format(step.ratio), format(step.hedged),
+			format(unhedgedPnl), format(hedgedPnl)})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,141 @@
+package hedging
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Returns of the asset with the hedge ratio changing from 0.5 to 1.5 in the middle
+func shiftingPair(seed int64, n int) ([]string, []float64, []float64) {
+	random := rand.New(rand.NewSource(seed))
+	dates := make([]string, n)
+	asset := make([]float64, n)
+	hedge := make([]float64, n)
+	for idx := range asset {
+		ratio := 0.5
+		if idx >= n/2 {
+			ratio = 1.5
+		}
+		dates[idx] = fmt.Sprintf("day %d", idx)
+		hedge[idx] = 0.02 * random.NormFloat64()
+		asset[idx] = ratio*hedge[idx] + 0.005*random.NormFloat64()
+	}
+	return dates, asset, hedge
+}
+
+func TestWalkForwardIsOutOfSample(t *testing.T) {
+	dates, asset, hedge := shiftingPair(1, 100)
+	result := walkForward(dates, asset, hedge, 20, 10, 0, cleaningSpec{method: noOutliers})
+
+	assert.Len(t, result.steps, 80)
+	assert.Len(t, result.rebalances, 8)
+	assert.Equal(t, "day 20", result.steps[0].date)
+	assert.Equal(t, "day 19", result.rebalances[0].date)
+
+	// The first ratio is estimated on the window before the first step
+	expected := linearRegression(hedge[:20], asset[:20]).Beta
+	assert.InDelta(t, expected, result.steps[0].ratio, 1e-12)
+	assert.Equal(t, result.steps[0].ratio, result.steps[9].ratio)
+	assert.NotEqual(t, result.steps[9].ratio, result.steps[10].ratio)
+	assert.InDelta(t, asset[20]-expected*hedge[20], result.steps[0].hedged, 1e-12)
+}
+
+func TestWalkForwardFollowsRegimeShift(t *testing.T) {
+	dates, asset, hedge := shiftingPair(2, 1000)
+	result := walkForward(dates, asset, hedge, 60, 5, 0, cleaningSpec{method: noOutliers})
+
+	assert.InDelta(t, 0.5, result.steps[100].ratio, 0.1)
+	assert.InDelta(t, 1.5, result.steps[len(result.steps)-1].ratio, 0.1)
+	assert.Greater(t, result.varianceReduction(), 0.8)
+	assert.Greater(t, result.turnover, 0.9)
+}
+
+func TestWalkForwardTurnover(t *testing.T) {
+	dates, asset, hedge := shiftingPair(3, 50)
+	result := walkForward(dates, asset, hedge, 10, 1, 0, cleaningSpec{method: noOutliers})
+
+	turnover := 0.0
+	for idx := 1; idx < len(result.rebalances); idx++ {
+		change := result.rebalances[idx].ratio - result.rebalances[idx-1].ratio
+		if change < 0 {
+			change = -change
+		}
+		turnover += change
+	}
+	assert.Len(t, result.rebalances, 40)
+	assert.InDelta(t, turnover, result.turnover, 1e-12)
+}
+
+func TestWalkForwardCleansEstimationWindowOnly(t *testing.T) {
+	dates, asset, hedge := shiftingPair(4, 60)
+	asset[45] = 0.5
+	cleaning := cleaningSpec{method: madOutliers, limit: defaultMADLimit, action: removeOutliers}
+	result := walkForward(dates, asset, hedge, 20, 10, 0, cleaning)
+
+	// The outlier does not change ratios estimated before it
+	raw := walkForward(dates, asset, hedge, 20, 10, 0, cleaningSpec{method: noOutliers})
+	assert.Equal(t, raw.rebalances[:3], result.rebalances[:3])
+	assert.NotEqual(t, raw.rebalances[3], result.rebalances[3])
+	assert.Greater(t, result.outliers, 0)
+
+	// Out of sample returns are raw
+	assert.Equal(t, 0.5, result.steps[25].asset)
+	assert.Len(t, result.steps, 40)
+}
+
+func TestEvaluate(t *testing.T) {
+	stats := evaluate([]float64{0.01, 0.02, -0.04, 0.01, 0.03}, 252)
+	assert.InDelta(t, 0.03, stats.pnl, 1e-12)
+	assert.InDelta(t, 0.04, stats.drawdown, 1e-12)
+	assert.Greater(t, stats.volatility, 0.0)
+
+	assert.Equal(t, 0.0, evaluate([]float64{0.01, 0.01}, 252).drawdown)
+}
+
+func TestBacktestPeriods(t *testing.T) {
+	window, step, err := backtestPeriods(Command{BacktestWindow: DefaultBacktestWindow, Rebalance: DefaultRebalancePeriod})
+	assert.Nil(t, err)
+	assert.Equal(t, 120, window)
+	assert.Equal(t, 5, step)
+
+	window, step, err = backtestPeriods(Command{BacktestWindow: 60, Rebalance: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, 60, window)
+	assert.Equal(t, 1, step)
+
+	// Rolling beta flags do not affect the backtest
+	_, _, err = backtestPeriods(Command{RollingWindow: 60, RollingStep: 5})
+	assert.NotNil(t, err)
+	_, _, err = backtestPeriods(Command{BacktestWindow: 2, Rebalance: 1})
+	assert.NotNil(t, err)
+	_, _, err = backtestPeriods(Command{BacktestWindow: 60})
+	assert.NotNil(t, err)
+}
+
+func TestExportBacktest(t *testing.T) {
+	result := backtestResult{steps: []backtestStep{
+		{date: "2024-01-03", asset: 0.02, hedge: 0.01, ratio: 1, hedged: 0.01},
+		{date: "2024-01-04", asset: -0.01, hedge: -0.02, ratio: 1, hedged: 0.01},
+	}}
+	filename := filepath.Join(t.TempDir(), "backtest.csv")
+	if err := exportBacktest(filename, result); err != nil {
+		t.Fatalf("Failed to export backtest: %v", err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read export: %v", err)
+	}
+	expected := "date,asset_return,hedge_return,hedge_ratio,hedged_return,unhedged_pnl,hedged_pnl\n" +
+		"2024-01-03,0.02,0.01,1,0.01,0.02,0.01\n" +
+		"2024-01-04,-0.01,-0.02,1,0.01,0.01,0.02\n"
+	if strings.TrimSpace(string(data)) != strings.TrimSpace(expected) {
+		t.Errorf("Unexpected export:\n%s", data)
+	}
+}
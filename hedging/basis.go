@@ -0,0 +1,256 @@
+package hedging
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/TuliMyrskyTaivas/hedging/moex"
+	"golang.org/x/text/message"
+	"gonum.org/v1/gonum/stat"
+)
+
+// Carry rates are annualized by calendar days
+const calendarDaysPerYear = 365
+
+// Largest median deviation of the converted future price from the spot
+// price, anything larger means the prices are in different units
+const maxBasisShare = 0.25
+
+type basisCalculator struct {
+	cache Cache
+}
+
+// ////////////////////////////////////////////////////////
+// Constructor
+// ////////////////////////////////////////////////////////
+func newBasisCalculator(cacheDSN string) (Executor, error) {
+	cache, err := NewCache(cacheDSN)
+	if err != nil {
+		return nil, err
+	}
+	return &basisCalculator{cache: cache}, nil
+}
+
+// Rule bringing the future price to the units of the spot price
+type priceConversion struct {
+	description string
+	factor      float64 // future price is multiplied by it
+}
+
+// Basis of the future on the date
+type basisPoint struct {
+	date   string
+	spot   float64 // price of the underlying asset
+	future float64 // price of the future in units of the underlying asset
+	basis  float64 // future minus spot
+	days   int     // calendar days to the last trading date
+	rate   float64 // annualized implied carry rate, NaN on the last trading date
+}
+
+// Statistics of the basis over the history window
+type basisStatistics struct {
+	meanBasis       float64 // mean basis, share of the spot price
+	basisStdDev     float64 // standard deviation of the basis, share of the spot price
+	meanRate        float64 // mean implied carry rate
+	rateStdDev      float64 // standard deviation of the implied carry rate
+	basisVolatility float64 // annualized volatility of basis changes, share of the spot price
+}
+
+// ////////////////////////////////////////////////////////
+// Command executor
+// ////////////////////////////////////////////////////////
+func (calculator *basisCalculator) Execute(command Command) error {
+	fmt.Printf("Calculate basis of %s\n", command.Hedge)
+
+	if len(command.Hedge) == 0 {
+		return fmt.Errorf("future was not specified. Run with -h for the help")
+	}
+	spec, err := newReturnSpec(command)
+	if err != nil {
+		return err
+	}
+
+	resolver := newAssetResolver(calculator.cache, command)
+	pair, err := fetchHedgePair(resolver, command)
+	if err != nil {
+		return err
+	}
+	info, err := resolver.GetFutureInfo(pair.hedge.Secid)
+	if err != nil {
+		return fmt.Errorf("failed to get specification of %s, basis is defined for futures only: %s", pair.hedge.Secid, err)
+	}
+	underlying, err := resolver.GetFutureUnderlyingAsset(pair.hedge)
+	if err != nil {
+		return err
+	}
+	if pair.asset.Secid != underlying.Secid {
+		return fmt.Errorf("%s is not the underlying asset of %s, which is %s", pair.asset.Secid, pair.hedge.Secid, underlying.Secid)
+	}
+	if len(info.Lasttradedate) == 0 {
+		return fmt.Errorf("MOEX reports no last trading date of %s", info.Secid)
+	}
+	expiry := moex.ParseTime(info.Lasttradedate)
+
+	dates, spot, future := pairedPrices(pair, spec)
+	if len(dates) < 2 {
+		return fmt.Errorf("%s and %s have %d common prices, at least 2 are required", pair.asset.Secid, pair.hedge.Secid, len(dates))
+	}
+	conversion, err := futureConversion(info, pair.asset.Secid, spot, future)
+	if err != nil {
+		return err
+	}
+	series := basisSeries(dates, spot, future, conversion.factor, expiry)
+
+	printer, err := GetPrinter()
+	if err != nil {
+		return err
+	}
+	printer.Printf("Basis of %s on %s by %s prices, future price is %s\n", pair.hedge.Secid, pair.asset.Secid, spec.prices, conversion.description)
+	printer.Printf("\tLast trading date %s, %d days left\n", info.Lasttradedate, daysToExpiry(time.Now(), expiry))
+	printBasis(printer, series, summarizeBasis(series, spec.periodsPerYear()))
+
+	if len(command.Output) > 0 {
+		if err = exportBasis(command.Output, series); err != nil {
+			return fmt.Errorf("failed to export basis: %s", err)
+		}
+		fmt.Printf("Basis exported to %s\n", command.Output)
+	}
+	return nil
+}
+
+// ////////////////////////////////////////////////////////
+// Bring the future price to the units of the spot price
+// by the contract specification. The price of the lot is
+// divided by the lot size (Si is quoted per 1000 USD, SBRF
+// per 100 shares), the price in points is valued in RUB by
+// the step price first. The first rule agreeing with the
+// spot prices is taken, prices of the underlying quoted in
+// other units or currency can not be related
+// ////////////////////////////////////////////////////////
+func futureConversion(info moex.FutureInfo, spotName string, spot []float64, future []float64) (priceConversion, error) {
+	if info.Lotvolume <= 0 {
+		return priceConversion{}, fmt.Errorf("MOEX reports no lot size of %s", info.Secid)
+	}
+
+	lot := float64(info.Lotvolume)
+	conversions := []priceConversion{{description: fmt.Sprintf("divided by lot size %d", info.Lotvolume), factor: 1 / lot}}
+	if info.Minstep > 0 && info.Stepprice > 0 && info.Stepprice != info.Minstep {
+		conversions = append(conversions, priceConversion{
+			description: fmt.Sprintf("valued by step price %g RUB per %g and divided by lot size %d", info.Stepprice, info.Minstep, info.Lotvolume),
+			factor:      info.Stepprice / info.Minstep / lot,
+		})
+	}
+
+	deviations := make([]float64, len(spot))
+	for _, conversion := range conversions {
+		for idx := range spot {
+			deviations[idx] = math.Abs(future[idx]*conversion.factor/spot[idx] - 1)
+		}
+		if median(deviations) <= maxBasisShare {
+			return conversion, nil
+		}
+	}
+	return priceConversion{}, fmt.Errorf("prices of %s do not match prices of %s by lot size %d and step price %g per %g, "+
+		"the underlying asset is quoted in other units or currency", info.Secid, spotName, info.Lotvolume, info.Stepprice, info.Minstep)
+}
+
+// ////////////////////////////////////////////////////////
+// Basis and implied carry rate on every date:
+// r = (F / S - 1) * 365 / days to the last trading date
+// ////////////////////////////////////////////////////////
+func basisSeries(dates []string, spot []float64, future []float64, factor float64, expiry time.Time) []basisPoint {
+	series := make([]basisPoint, len(dates))
+	for idx, date := range dates {
+		point := basisPoint{date: date, spot: spot[idx], future: future[idx] * factor}
+		point.basis = point.future - point.spot
+		point.days = daysToExpiry(moex.ParseTime(date), expiry)
+		point.rate = math.NaN()
+		if point.days > 0 {
+			point.rate = point.basis / point.spot * calendarDaysPerYear / float64(point.days)
+		}
+		series[idx] = point
+	}
+	return series
+}
+
+// Calendar days from the date to the expiry, negative after it
+func daysToExpiry(date time.Time, expiry time.Time) int {
+	date = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	return int(math.Round(expiry.Sub(date).Hours() / 24))
+}
+
+// ////////////////////////////////////////////////////////
+// Level and volatility of the basis relative to the spot
+// ////////////////////////////////////////////////////////
+func summarizeBasis(series []basisPoint, periodsPerYear float64) basisStatistics {
+	var result basisStatistics
+	relative := make([]float64, len(series))
+	var rates []float64
+	for idx, point := range series {
+		relative[idx] = point.basis / point.spot
+		if !math.IsNaN(point.rate) {
+			rates = append(rates, point.rate)
+		}
+	}
+	result.meanBasis, result.basisStdDev = stat.MeanStdDev(relative, nil)
+	result.meanRate, result.rateStdDev = math.NaN(), math.NaN()
+	if len(rates) > 1 {
+		result.meanRate, result.rateStdDev = stat.MeanStdDev(rates, nil)
+	}
+
+	changes := make([]float64, len(series)-1)
+	for idx := range changes {
+		changes[idx] = (series[idx+1].basis - series[idx].basis) / series[idx].spot
+	}
+	result.basisVolatility = stat.StdDev(changes, nil) * math.Sqrt(periodsPerYear)
+	return result
+}
+
+// ////////////////////////////////////////////////////////
+// Print current basis, its statistics and history
+// ////////////////////////////////////////////////////////
+func printBasis(printer *message.Printer, series []basisPoint, stats basisStatistics) {
+	last := series[len(series)-1]
+	printer.Printf("\tBasis on %s is %f (%.3f%% of spot), implied carry rate %.2f%% for %d days\n", last.date, last.basis,
+		last.basis/last.spot*100, last.rate*100, last.days)
+	printer.Printf("\tMean basis %.3f%% of spot, standard deviation %.3f%%, annualized volatility of basis changes %.3f%%\n",
+		stats.meanBasis*100, stats.basisStdDev*100, stats.basisVolatility*100)
+	printer.Printf("\tMean implied carry rate %.2f%%, standard deviation %.2f%%\n", stats.meanRate*100, stats.rateStdDev*100)
+	printer.Printf("\t%-10s %12s %12s %12s %8s %10s\n", "Date", "Spot", "Future", "Basis", "Days", "Carry, %")
+	for _, point := range series {
+		printer.Printf("\t%-10s %12f %12f %12f %8d %10.2f\n", point.date, point.spot, point.future, point.basis, point.days, point.rate*100)
+	}
+}
+
+// ////////////////////////////////////////////////////////
+// Write basis history to CSV file
+// ////////////////////////////////////////////////////////
+func exportBasis(filename string, series []basisPoint) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if err = writer.Write([]string{"date", "spot", "future", "basis", "days", "carry_rate"}); err != nil {
+		return err
+	}
+	format := func(value float64) string {
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+	for _, point := range series {
+		err = writer.Write([]string{point.date, format(point.spot), format(point.future), format(point.basis),
+			strconv.Itoa(point.days), format(point.rate)})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,88 @@
+package hedging
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TuliMyrskyTaivas/hedging/moex"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFutureConversion(t *testing.T) {
+	// Si future is quoted per lot of 1000 USD
+	si := moex.FutureInfo{Secid: "SiZ4", Lotvolume: 1000, Minstep: 1, Stepprice: 1}
+	conversion, err := futureConversion(si, "USD000UTSTOM", []float64{90, 91, 92}, []float64{91500, 92400, 93700})
+	assert.NoError(t, err)
+	assert.Equal(t, 0.001, conversion.factor)
+	assert.Equal(t, "divided by lot size 1000", conversion.description)
+
+	// Index future with point costing 0.1 RUB
+	mini := moex.FutureInfo{Secid: "MXIZ4", Lotvolume: 1, Minstep: 0.5, Stepprice: 0.05}
+	conversion, err = futureConversion(mini, "IMOEX", []float64{3200, 3250}, []float64{32800, 33100})
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.1, conversion.factor, 1e-12)
+
+	// Gold future is quoted in USD per troy ounce, the spot in RUB per gram
+	gold := moex.FutureInfo{Secid: "GDZ4", Lotvolume: 1, Minstep: 0.1, Stepprice: 9.2}
+	_, err = futureConversion(gold, "GLDRUB_TOM", []float64{7800, 7900}, []float64{2650, 2680})
+	assert.Error(t, err)
+
+	_, err = futureConversion(moex.FutureInfo{Secid: "XXZ4"}, "XX", []float64{1}, []float64{1})
+	assert.EqualError(t, err, "MOEX reports no lot size of XXZ4")
+}
+
+func TestDaysToExpiry(t *testing.T) {
+	expiry := moex.ParseTime("2024-03-21")
+	assert.Equal(t, 20, daysToExpiry(moex.ParseTime("2024-03-01"), expiry))
+	assert.Equal(t, 0, daysToExpiry(moex.ParseTime("2024-03-21"), expiry))
+	assert.Equal(t, 20, daysToExpiry(time.Date(2024, 3, 1, 18, 30, 0, 0, time.UTC), expiry))
+}
+
+func TestBasisSeries(t *testing.T) {
+	expiry := moex.ParseTime("2024-12-31")
+	series := basisSeries([]string{"2024-01-01", "2024-12-31"}, []float64{100, 100}, []float64{110000, 100000}, 0.001, expiry)
+
+	assert.Len(t, series, 2)
+	assert.Equal(t, 110.0, series[0].future)
+	assert.InDelta(t, 10, series[0].basis, 1e-12)
+	assert.Equal(t, 365, series[0].days)
+	assert.InDelta(t, 0.1, series[0].rate, 1e-12)
+
+	// The carry rate is not defined on the last trading date
+	assert.Equal(t, 0, series[1].days)
+	assert.True(t, math.IsNaN(series[1].rate))
+}
+
+func TestSummarizeBasis(t *testing.T) {
+	series := []basisPoint{
+		{spot: 100, basis: 2, rate: 0.1},
+		{spot: 100, basis: 1, rate: 0.12},
+		{spot: 100, basis: 0, rate: math.NaN()},
+	}
+	stats := summarizeBasis(series, 252)
+	assert.InDelta(t, 0.01, stats.meanBasis, 1e-12)
+	assert.InDelta(t, 0.01, stats.basisStdDev, 1e-12)
+	assert.InDelta(t, 0.11, stats.meanRate, 1e-12)
+	assert.InDelta(t, 0, stats.basisVolatility, 1e-12)
+}
+
+func TestExportBasis(t *testing.T) {
+	series := []basisPoint{{date: "2024-01-03", spot: 100, future: 101, basis: 1, days: 73, rate: 0.05}}
+	filename := filepath.Join(t.TempDir(), "basis.csv")
+	if err := exportBasis(filename, series); err != nil {
+		t.Fatalf("Failed to export basis: %v", err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read export: %v", err)
+	}
+	expected := "date,spot,future,basis,days,carry_rate\n2024-01-03,100,101,1,73,0.05\n"
+	if strings.TrimSpace(string(data)) != strings.TrimSpace(expected) {
+		t.Errorf("Unexpected export:\n%s", data)
+	}
+}
@@ -3,28 +3,28 @@ package hedging
 import (
 	"fmt"
 	"log/slog"
+	"math"
 	"strings"
 	"time"
 
 	"github.com/TuliMyrskyTaivas/hedging/moex"
-	"gonum.org/v1/gonum/stat"
+	"golang.org/x/text/message"
 )
 
 type betaCalculator struct {
-	cache *Cache
+	cache Cache
 }
 
 // ////////////////////////////////////////////////////////
 // Constructor
 // ////////////////////////////////////////////////////////
-func newBetaCalculator() (Executor, error) {
-	const cacheFile = "cache.db"
-	cache, err := NewCache(cacheFile)
+func newBetaCalculator(cacheDSN string) (Executor, error) {
+	cache, err := NewCache(cacheDSN)
 	if err != nil {
 		return nil, err
 	}
 
-	err = cache.PrintStats()
+	err = PrintStats(cache)
 	if err != nil {
 		return nil, err
 	}
@@ -34,7 +34,13 @@ func newBetaCalculator() (Executor, error) {
 
 type betaReport struct {
 	asset string
-	beta  float64
+	Regression
+	rolling []RollingBeta
+	lagged  *LaggedBeta
+	regimes RegimeBetas
+	robust  []robustFit
+	kalman  *kalmanFit
+	cleaned []cleaningSummary
 }
 
 // ////////////////////////////////////////////////////////
@@ -48,12 +54,48 @@ func (calculator *betaCalculator) Execute(command Command) error {
 		return fmt.Errorf("index was not specified. Run with -h for the help")
 	}
 
-	if len(command.Asset) == 0 {
+	if len(command.Asset) == 0 && len(command.Portfolio) == 0 {
 		return fmt.Errorf("asset was not specified. Run with -h for the help")
 	}
 
+	if err := validateRollingWindow(command); err != nil {
+		return err
+	}
+	if err := validateWeighting(command); err != nil {
+		return err
+	}
+	if command.Lags < 0 {
+		return fmt.Errorf("number of lags must not be negative")
+	}
+	if err := validateKalman(command); err != nil {
+		return err
+	}
+	if _, err := parseThreshold(command.Threshold, nil, nil); err != nil {
+		return err
+	}
+	spec, err := newReturnSpec(command)
+	if err != nil {
+		return err
+	}
+	cleaning, err := newCleaningSpec(command)
+	if err != nil {
+		return err
+	}
+	if len(command.Portfolio) > 0 {
+		err = rejectOptions("portfolio beta", []commandOption{
+			{flag: "window", set: command.RollingWindow > 0},
+			{flag: "lags", set: command.Lags > 0},
+			{flag: "robust", set: command.Robust},
+			{flag: "kalman", set: command.Kalman},
+		})
+		if err != nil {
+			return err
+		}
+		return calculator.executePortfolio(command, spec, cleaning)
+	}
+
 	// Create report file if requested
-	var report *Report = nil
+	var report Report = nil
 	if len(command.Report) > 0 {
 		var err error
 		report, err = NewReport(command.Report)
@@ -69,9 +111,10 @@ func (calculator *betaCalculator) Execute(command Command) error {
 	errors := make(chan error, len(assetNames)+1)
 
 	// Query info on index and assets
-	go getAsset(command.Hedge, assetResults, errors)
+	resolver := newAssetResolver(calculator.cache, command)
+	go getAsset(resolver, command.Hedge, assetResults, errors)
 	for _, asset := range assetNames {
-		go getAsset(asset, assetResults, errors)
+		go getAsset(resolver, asset, assetResults, errors)
 	}
 
 	// Read results or stop if any error occurred
@@ -93,10 +136,13 @@ func (calculator *betaCalculator) Execute(command Command) error {
 		}
 	}
 
-	// Calculate beta on assets
+	// Calculate beta on assets, profits are written to the cache by the single writer
+	writer := NewCacheWriter(calculator.cache)
+	defer writer.Close()
+
 	betaResults := make(chan betaReport, len(assetNames))
 	for _, asset := range assets {
-		go calcBeta(asset, index, command.HistoryDepth, calculator.cache, betaResults, errors)
+		go calcBeta(asset, index, command, writer, betaResults, errors)
 	}
 	// Read the results of calculation or stop on first error
 	var betas []betaReport
@@ -113,29 +159,83 @@ func (calculator *betaCalculator) Execute(command Command) error {
 		}
 	}
 
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to save profits to cache: %s", err)
+	}
+
 	// Print the results
 	printer, err := GetPrinter()
 	if err != nil {
 		return err
 	}
+	printer.Printf("Beta is estimated on %s taken with %s\n", spec, describeWeighting(decayFactor(command)))
 	for _, beta := range betas {
 		if report != nil {
-			err = report.AddReport(beta.asset, index.Secid, beta.beta, time.Now().Format("2006-01-02 15:04:05"))
+			err = report.AddReport(beta.asset, index.Secid, beta.Regression, beta.regimes, time.Now().Format("2006-01-02 15:04:05"))
 			if err != nil {
 				return fmt.Errorf("failed to add line to report: %s", err)
 			}
 		}
-		printer.Printf("Beta coefficient for last %d month %s on %s is %f\n", command.HistoryDepth, beta.asset, index.Secid, beta.beta)
+		printer.Printf("Beta coefficient for last %d month %s on %s is %f\n", command.HistoryDepth, beta.asset, index.Secid, beta.Beta)
+		for _, summary := range beta.cleaned {
+			printer.Printf("\t%s\n", describeCleaning(cleaning, summary))
+		}
+		printRegression(printer, beta.Regression, spec)
+		printRegimeBetas(printer, beta.regimes)
+		if beta.lagged != nil {
+			printLaggedBeta(printer, *beta.lagged)
+		}
+		if beta.robust != nil {
+			printRobustFits(printer, "Beta", beta.robust)
+		}
+		if beta.kalman != nil {
+			printKalmanBeta(printer, "beta", beta.asset, index.Secid, *beta.kalman)
+		}
+	}
+
+	if len(betas) > 1 {
+		printAdjustedBetas(printer, betas)
+	}
+
+	if command.RollingWindow == 0 {
+		return nil
+	}
+
+	for _, beta := range betas {
+		if report != nil {
+			err = report.AddRollingBeta(beta.asset, index.Secid, command.RollingWindow, beta.rolling)
+			if err != nil {
+				return fmt.Errorf("failed to add rolling beta to report: %s", err)
+			}
+		}
+		printRollingBeta(printer, beta.asset, index.Secid, command.RollingWindow, beta.rolling)
+	}
+
+	if len(command.Output) > 0 {
+		if err = exportRollingBeta(command.Output, index.Secid, betas); err != nil {
+			return fmt.Errorf("failed to export rolling beta: %s", err)
+		}
+		fmt.Printf("Rolling beta exported to %s\n", command.Output)
 	}
 
 	return nil
 }
 
+// ////////////////////////////////////////////////////////
+// Print full statistics of the regression
+// ////////////////////////////////////////////////////////
+func printRegression(printer *message.Printer, stats Regression, spec returnSpec) {
+	printer.Printf("\talpha %f (standard error %f, t-stat %f, p-value %f)\n", stats.Alpha, stats.AlphaStdErr, stats.AlphaTStat, stats.AlphaPValue)
+	printer.Printf("\tbeta  %f (standard error %f, t-stat %f, p-value %f)\n", stats.Beta, stats.BetaStdErr, stats.BetaTStat, stats.BetaPValue)
+	printer.Printf("\tR² %f, residual volatility %f (annualized %f), %d observations\n", stats.RSquared,
+		stats.ResidualVolatility, stats.ResidualVolatility*math.Sqrt(spec.periodsPerYear()), stats.Observations)
+}
+
 // ////////////////////////////////////////////////////////
 // Get info on MOEX asset asynchronously
 // ////////////////////////////////////////////////////////
-func getAsset(assetName string, result chan moex.Asset, errResult chan error) {
-	asset, err := moex.GetAsset(assetName)
+func getAsset(resolver *assetResolver, assetName string, result chan moex.Asset, errResult chan error) {
+	asset, err := resolver.GetAsset(assetName)
 	if err != nil {
 		errResult <- err
 	} else {
@@ -143,10 +243,10 @@ func getAsset(assetName string, result chan moex.Asset, errResult chan error) {
 	}
 }
 
-func calcBeta(asset moex.Asset, index moex.Asset, depthMonth int, cache *Cache, result chan betaReport, errResult chan error) {
+func calcBeta(asset moex.Asset, index moex.Asset, command Command, writer *CacheWriter, result chan betaReport, errResult chan error) {
 	// Adjust range on availability of data on MOEX
 	historyTo := time.Now()
-	historyFrom := historyTo.AddDate(0, -depthMonth, 0)
+	historyFrom := historyTo.AddDate(0, -command.HistoryDepth, 0)
 	indexHistoryBegin := moex.ParseTime(index.HistoryFrom)
 	assetHistoryBegin := moex.ParseTime(asset.HistoryFrom)
 
@@ -169,108 +269,62 @@ func calcBeta(asset moex.Asset, index moex.Asset, depthMonth int, cache *Cache,
 		return
 	}
 
-	// Normalize histories: leave only items with the same date
-	if len(indexHistory) > len(assetHistory) {
-		indexHistory = filterHistory(assetHistory, indexHistory)
-	} else if len(assetHistory) > len(indexHistory) {
-		assetHistory = filterHistory(indexHistory, assetHistory)
-	}
+	// Cache keeps raw daily returns of every ticker over its own history,
+	// returns of the pair span only the dates both tickers were traded on
+	saveProfits(writer, asset.Secid, assetHistory)
+	saveProfits(writer, index.Secid, indexHistory)
 
-	slog.Debug(fmt.Sprintf("history of %s contains %d items, history of %s contains %d items",
-		asset.Secid, len(assetHistory), index.Secid, len(indexHistory)))
+	spec, _ := newReturnSpec(command)
+	dates, assetProfits, indexProfits := pairedReturns(asset, assetHistory, index, indexHistory, spec)
 
-	indexProfits := getProfits(indexHistory)
-	assetProfits := getProfits(assetHistory)
-
-	if profitsCalculatedWrong(indexProfits) {
-		slog.Debug("seems that MOEX reports same open and close prices for the index, recalculating profits...")
-		indexProfits = getOvernightProfits(indexHistory)
-	}
+	cleaning, _ := newCleaningSpec(command)
+	var cleaned []cleaningSummary
+	dates, assetProfits, indexProfits, cleaned = cleanReturns(cleaning, dates, asset.Secid, assetProfits, index.Secid, indexProfits)
 
-	saveProfits(cache, asset.Secid, assetHistory, assetProfits)
-	saveProfits(cache, index.Secid, indexHistory, indexProfits)
-
-	indexStdDev := stat.StdDev(indexProfits, nil)
-	beta := stat.Covariance(indexProfits, assetProfits, nil) / (indexStdDev * indexStdDev)
-	result <- betaReport{asset.Secid, beta}
-}
-
-// ////////////////////////////////////////////////////////
-// Get profits as difference of close and open prices
-// ////////////////////////////////////////////////////////
-func filterHistory(baseLine []moex.HistoryItem, input []moex.HistoryItem) []moex.HistoryItem {
-	output := input[:0]
-	for i, j := 0, 0; i < len(baseLine) && j < len(input); {
-		baseDate := moex.ParseTime(baseLine[i].Tradedate)
-		inputDate := moex.ParseTime(input[j].Tradedate)
-		if baseDate == inputDate {
-			output = append(output, input[i])
-			i++
-			j++
-		} else if baseDate.Before(inputDate) {
-			i++
-		} else {
-			j++
-		}
+	weights := ewmaWeights(len(indexProfits), decayFactor(command))
+	report := betaReport{asset: asset.Secid, Regression: weightedRegression(indexProfits, assetProfits, weights), cleaned: cleaned}
+	threshold, err := parseThreshold(command.Threshold, indexProfits, weights)
+	if err != nil {
+		errResult <- err
+		return
 	}
-
-	// Validate
-	for idx, item := range output {
-		if baseLine[idx].Tradedate != item.Tradedate {
-			slog.Error(fmt.Sprintf("Dates not equal at idx %d: %s != %s", idx, baseLine[idx].Tradedate, item.Tradedate))
+	report.regimes = regimeRegression(indexProfits, assetProfits, weights, threshold)
+	if command.Lags > 0 {
+		lagged, err := laggedRegression(indexProfits, assetProfits, command.Lags)
+		if err != nil {
+			errResult <- fmt.Errorf("failed to calculate lagged beta of %s: %s", asset.Secid, err)
+			return
 		}
+		report.lagged = &lagged
 	}
-	return output
-}
-
-// ////////////////////////////////////////////////////////
-// Get profits as difference of close and open prices
-// ////////////////////////////////////////////////////////
-func getProfits(history []moex.HistoryItem) []float64 {
-	var profits []float64
-	for _, item := range history {
-		if item.Open == 0 {
-			profits = append(profits, 0)
-		} else {
-			profits = append(profits, (item.Close-item.Open)/item.Open)
-		}
+	if command.Robust {
+		report.robust = robustRegressions(indexProfits, assetProfits)
 	}
-	return profits
-}
-
-// ////////////////////////////////////////////////////////
-// Get profits as difference of close prices overnight
-// ////////////////////////////////////////////////////////
-func getOvernightProfits(history []moex.HistoryItem) []float64 {
-	var profits []float64
-	var prevClose float64 = 0
-	for _, item := range history {
-		if prevClose == 0 {
-			profits = append(profits, 0)
-		} else {
-			profits = append(profits, (item.Close-prevClose)/prevClose)
+	if command.Kalman {
+		kalman, err := kalmanRegression(dates, indexProfits, assetProfits, command.ProcessNoise, command.ObservationNoise)
+		if err != nil {
+			errResult <- fmt.Errorf("failed to filter beta of %s: %s", asset.Secid, err)
+			return
 		}
-		prevClose = item.Close
+		report.kalman = &kalman
 	}
-	return profits
-}
-
-// ////////////////////////////////////////////////////////
-// Check whether profits were calculated wrong (sum is zero)
-// ////////////////////////////////////////////////////////
-func profitsCalculatedWrong(profits []float64) bool {
-	var sum float64
-	for _, item := range profits {
-		sum += item
+	if command.RollingWindow > 0 {
+		report.rolling = rollingRegression(dates, indexProfits, assetProfits, command.RollingWindow, command.RollingStep)
 	}
-
-	return sum == 0
+	result <- report
 }
 
-func saveProfits(cache *Cache, asset string, history []moex.HistoryItem, profits []float64) {
-	var dates []string
-	for _, item := range history {
-		dates = append(dates, item.Tradedate)
+func saveProfits(writer *CacheWriter, asset string, history []moex.HistoryItem) {
+	dates, profits := historyReturns(asset, history, defaultReturnSpec)
+	var stableDates []string
+	var stableProfits []float64
+	for idx, date := range dates {
+		if isVolatileBar(date) {
+			slog.Debug(fmt.Sprintf("bar of %s on %s is not final, skip caching it", asset, date))
+			continue
+		}
+		stableDates = append(stableDates, date)
+		stableProfits = append(stableProfits, profits[idx])
 	}
-	cache.AddProfits(asset, dates, profits)
+	writer.Write(asset, stableDates, stableProfits)
 }
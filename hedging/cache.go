@@ -1,104 +1,153 @@
 package hedging
 
 import (
-	"database/sql"
+	"encoding/csv"
 	"fmt"
+	"io"
 	"log/slog"
+	"strconv"
 	"strings"
 	"time"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
-type Cache struct {
-	db *sql.DB
+// Storage of daily profits of assets
+type Cache interface {
+	GetAvailableRange(ticker string) (time.Time, time.Time, error)
+	GetTickers() ([]string, error)
+	GetProfits(ticker string) ([]string, []float64, error)
+	CountProfits(ticker string) (int, error)
+	AddProfits(ticker string, dates []string, profits []float64) error
+	PurgeTicker(ticker string) (int64, error)
+	PurgeOlderThan(date time.Time) (int64, error)
+
+	// Metadata is an opaque blob stored with the time it was fetched,
+	// nil value is returned if nothing is stored under the key
+	GetMetadata(kind string, key string) ([]byte, time.Time, error)
+	PutMetadata(kind string, key string, value []byte) error
+
+	Vacuum() error
+	Close() error
+
+	// Write profits of several tickers at once (in one transaction if supported)
+	addProfitsBatches(batches []profitsBatch) error
 }
 
-func NewCache(filename string) (*Cache, error) {
-	if filename == "" {
-		filename = "cache.db"
-	}
-	db, err := sql.Open("sqlite3", filename)
-	if err != nil {
-		return nil, err
+// ////////////////////////////////////////////////////////
+// Open cache by DSN, see parseDSN for supported formats
+// ////////////////////////////////////////////////////////
+func NewCache(dsn string) (Cache, error) {
+	if dsn == "" {
+		dsn = "cache.db"
 	}
 
-	const createProfitsTable string = `
-		CREATE TABLE IF NOT EXISTS profits (
-			ticker STRING NOT NULL,
-			date DATETIME NOT NULL,
-			profit REAL,
-			PRIMARY KEY (ticker, date)
-		)`
-
-	if _, err = db.Exec(createProfitsTable); err != nil {
-		return nil, err
+	backend, _ := parseDSN(dsn)
+	if backend == memoryBackend {
+		return newMemoryCache(), nil
 	}
-
-	return &Cache{db: db}, nil
+	return newSQLCache(dsn)
 }
 
-func (cache *Cache) GetAvailableRange(asset string) (time.Time, time.Time, error) {
-	result := cache.db.QueryRow("SELECT min(date), max(date) FROM profits WHERE ticker=?", asset)
-
-	var from, till string
-	err := result.Scan(&from, &till)
+// ////////////////////////////////////////////////////////
+// Print total number of cached records
+// ////////////////////////////////////////////////////////
+func PrintStats(cache Cache) error {
+	tickers, err := cache.GetTickers()
 	if err != nil {
-		return time.Time{}, time.Time{}, err
+		return err
 	}
 
-	const TimeFormat = "2006-01-02"
-	fromTime, err := time.Parse(TimeFormat, from)
-	if err != nil {
-		return time.Time{}, time.Time{}, err
+	count := 0
+	for _, ticker := range tickers {
+		records, err := cache.CountProfits(ticker)
+		if err != nil {
+			return err
+		}
+		count += records
 	}
+	slog.Debug(fmt.Sprintf("cache contains %d entries", count))
+	return nil
+}
 
-	tillTime, err := time.Parse(TimeFormat, till)
+// ////////////////////////////////////////////////////////
+// Write all cached profits as CSV: ticker, date, profit
+// ////////////////////////////////////////////////////////
+func ExportProfits(cache Cache, output io.Writer) (int, error) {
+	tickers, err := cache.GetTickers()
 	if err != nil {
-		return time.Time{}, time.Time{}, err
+		return 0, err
 	}
 
-	return fromTime, tillTime, nil
-}
-
-func (cache *Cache) PrintStats() error {
-	result := cache.db.QueryRow("SELECT COUNT(date) FROM profits")
+	writer := csv.NewWriter(output)
+	if err = writer.Write([]string{"ticker", "date", "profit"}); err != nil {
+		return 0, err
+	}
 
-	var count int
-	err := result.Scan(&count)
-	if err == nil {
-		slog.Debug(fmt.Sprintf("cache contains %d entries", count))
+	count := 0
+	for _, ticker := range tickers {
+		dates, profits, err := cache.GetProfits(ticker)
+		if err != nil {
+			return count, err
+		}
+		for idx, date := range dates {
+			err = writer.Write([]string{ticker, date, strconv.FormatFloat(profits[idx], 'g', -1, 64)})
+			if err != nil {
+				return count, err
+			}
+			count++
+		}
 	}
 
-	return err
+	writer.Flush()
+	return count, writer.Error()
 }
 
-func (cache *Cache) AddProfits(ticker string, dates []string, profits []float64) error {
-	valueStrings := make([]string, 0, len(dates))
-	valueArgs := make([]interface{}, 0, len(dates)*3)
+// ////////////////////////////////////////////////////////
+// Read profits in the format produced by ExportProfits,
+// existing records for the same ticker and date are replaced
+// ////////////////////////////////////////////////////////
+func ImportProfits(cache Cache, input io.Reader) (int, error) {
+	reader := csv.NewReader(input)
+	reader.FieldsPerRecord = 3
 
-	slog.Debug(fmt.Sprintf("insert %d profit records for %s", len(dates), ticker))
-	if len(dates) != len(profits) {
-		panic("the sizes of the sequences of dates and profits do not match")
+	header, err := reader.Read()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read CSV header: %s", err)
 	}
-
-	for idx, date := range dates {
-		valueStrings = append(valueStrings, "(?, ?, ?)")
-		valueArgs = append(valueArgs, ticker)
-		valueArgs = append(valueArgs, date)
-		valueArgs = append(valueArgs, profits[idx])
+	if header[0] != "ticker" || header[1] != "date" || header[2] != "profit" {
+		return 0, fmt.Errorf("unexpected CSV header: %s", strings.Join(header, ","))
 	}
 
-	statement := fmt.Sprintf("INSERT INTO profits (ticker, date, profit) VALUES %s", strings.Join(valueStrings, ","))
-	result, err := cache.db.Exec(statement, valueArgs...)
-
-	if err != nil {
-		return err
+	var batches []profitsBatch
+	count := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		const TimeFormat = "2006-01-02"
+		if _, err = time.Parse(TimeFormat, record[1]); err != nil {
+			return 0, fmt.Errorf("wrong date at line %d: %s", count+2, record[1])
+		}
+		profit, err := strconv.ParseFloat(record[2], 64)
+		if err != nil {
+			return 0, fmt.Errorf("wrong profit at line %d: %s", count+2, record[2])
+		}
+
+		if len(batches) == 0 || batches[len(batches)-1].ticker != record[0] {
+			batches = append(batches, profitsBatch{ticker: record[0]})
+		}
+		batch := &batches[len(batches)-1]
+		batch.dates = append(batch.dates, record[1])
+		batch.profits = append(batch.profits, profit)
+		count++
 	}
 
-	inserted, err := result.RowsAffected()
-	if err == nil {
-		slog.Debug(fmt.Sprintf("%d profit records inserted for %s", inserted, ticker))
+	if err = cache.addProfitsBatches(batches); err != nil {
+		return 0, err
 	}
-	return err
+	return count, nil
 }
@@ -1,7 +1,9 @@
 package hedging
 
 import (
+	"bytes"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -13,7 +15,7 @@ const (
 	TestDBFile = "test_cache.db"
 )
 
-func setupTestDB(t *testing.T) *Cache {
+func setupTestDB(t *testing.T) *sqlCache {
 	t.Helper()
 
 	// Remove any existing test database
@@ -25,12 +27,13 @@ func setupTestDB(t *testing.T) *Cache {
 		t.Fatalf("failed to create cache: %v", err)
 	}
 
-	return cache
+	return cache.(*sqlCache)
 }
 
-func teardownTestDB(cache *Cache) {
-	cache.db.Close()
+func teardownTestDB(cache *sqlCache) {
+	cache.Close()
 	_ = os.Remove(TestDBFile)
+	_ = os.Remove(TestDBFile + ".lock")
 }
 
 func TestNewCache(t *testing.T) {
@@ -117,8 +120,87 @@ func TestPrintStats(t *testing.T) {
 		t.Fatalf("failed to add profits: %v", err)
 	}
 
-	err = cache.PrintStats()
+	err = PrintStats(cache)
 	if err != nil {
 		t.Fatalf("failed to print stats: %v", err)
 	}
 }
+
+func TestPurge(t *testing.T) {
+	cache := setupTestDB(t)
+	defer teardownTestDB(cache)
+
+	err := cache.AddProfits("AAPL", []string{"2023-01-01", "2023-01-02"}, []float64{0.1, 0.2})
+	if err != nil {
+		t.Fatalf("failed to add profits: %v", err)
+	}
+	err = cache.AddProfits("MSFT", []string{"2023-01-01", "2023-02-01"}, []float64{0.3, 0.4})
+	if err != nil {
+		t.Fatalf("failed to add profits: %v", err)
+	}
+
+	removed, err := cache.PurgeOlderThan(time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC))
+	if err != nil || removed != 2 {
+		t.Fatalf("expected 2 records removed by date, got %d (%v)", removed, err)
+	}
+
+	removed, err = cache.PurgeTicker("MSFT")
+	if err != nil || removed != 1 {
+		t.Fatalf("expected 1 record removed by ticker, got %d (%v)", removed, err)
+	}
+
+	tickers, err := cache.GetTickers()
+	if err != nil {
+		t.Fatalf("failed to get tickers: %v", err)
+	}
+	if len(tickers) != 1 || tickers[0] != "AAPL" {
+		t.Fatalf("expected only AAPL to remain, got %v", tickers)
+	}
+
+	if err = cache.Vacuum(); err != nil {
+		t.Fatalf("failed to vacuum: %v", err)
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	cache := setupTestDB(t)
+	defer teardownTestDB(cache)
+
+	err := cache.AddProfits("AAPL", []string{"2023-01-01", "2023-01-02"}, []float64{0.125, -0.5})
+	if err != nil {
+		t.Fatalf("failed to add profits: %v", err)
+	}
+
+	var buffer bytes.Buffer
+	count, err := ExportProfits(cache, &buffer)
+	if err != nil || count != 2 {
+		t.Fatalf("expected 2 records exported, got %d (%v)", count, err)
+	}
+
+	expected := "ticker,date,profit\nAAPL,2023-01-01,0.125\nAAPL,2023-01-02,-0.5\n"
+	if buffer.String() != expected {
+		t.Fatalf("unexpected export:\n%s", buffer.String())
+	}
+
+	if _, err = cache.PurgeTicker("AAPL"); err != nil {
+		t.Fatalf("failed to purge: %v", err)
+	}
+
+	// Importing twice must not fail on duplicates
+	for i := 0; i < 2; i++ {
+		count, err = ImportProfits(cache, strings.NewReader(expected))
+		if err != nil || count != 2 {
+			t.Fatalf("expected 2 records imported, got %d (%v)", count, err)
+		}
+	}
+
+	records, err := cache.CountProfits("AAPL")
+	if err != nil || records != 2 {
+		t.Fatalf("expected 2 records in cache, got %d (%v)", records, err)
+	}
+
+	_, err = ImportProfits(cache, strings.NewReader("ticker,date,profit\nAAPL,yesterday,0.1\n"))
+	if err == nil {
+		t.Fatal("expected error on wrong date")
+	}
+}
@@ -0,0 +1,299 @@
+package hedging
+
+import (
+	"fmt"
+	"math"
+
+	"golang.org/x/text/message"
+	"gonum.org/v1/gonum/mat"
+)
+
+// Significance levels of the tabulated critical values
+var criticalLevels = [3]string{"1%", "5%", "10%"}
+
+// Response surface of Engle-Granger critical values for two variables
+// with constant, MacKinnon (2010): b0 + b1/T + b2/T^2
+var engleGrangerSurface = [3][3]float64{
+	{-3.89644, -10.9519, -22.527},
+	{-3.33613, -6.1101, -6.823},
+	{-3.04445, -4.2412, -2.720},
+}
+
+type cointCalculator struct {
+	cache Cache
+}
+
+// ////////////////////////////////////////////////////////
+// Constructor
+// ////////////////////////////////////////////////////////
+func newCointCalculator(cacheDSN string) (Executor, error) {
+	cache, err := NewCache(cacheDSN)
+	if err != nil {
+		return nil, err
+	}
+	return &cointCalculator{cache: cache}, nil
+}
+
+// Augmented Dickey-Fuller test of a unit root
+type adfResult struct {
+	statistic    float64
+	lags         int
+	observations int
+	critical     [3]float64 // at criticalLevels
+}
+
+// Engle-Granger two-step cointegration test
+type engleGranger struct {
+	alpha    float64 // intercept of the long-run relationship
+	ratio    float64 // units of the hedge per unit of the asset
+	adf      adfResult
+	halfLife float64 // half-life of the spread in observations, +Inf if it does not revert
+}
+
+// ////////////////////////////////////////////////////////
+// Command executor
+// ////////////////////////////////////////////////////////
+func (calculator *cointCalculator) Execute(command Command) error {
+	fmt.Printf("Test cointegration of %s and %s\n", command.Asset, command.Hedge)
+
+	if len(command.Hedge) == 0 {
+		return fmt.Errorf("hedge asset was not specified. Run with -h for the help")
+	}
+	if command.JohansenLags < 0 {
+		return fmt.Errorf("number of lagged differences of Johansen test must not be negative")
+	}
+	spec, err := newReturnSpec(command)
+	if err != nil {
+		return err
+	}
+
+	resolver := newAssetResolver(calculator.cache, command)
+	pair, err := fetchHedgePair(resolver, command)
+	if err != nil {
+		return err
+	}
+
+	_, assetPrices, hedgePrices := pairedPrices(pair, spec)
+	fmt.Printf("Prices are sampled %s, %d observations\n", spec.frequency, len(assetPrices))
+
+	printer, err := GetPrinter()
+	if err != nil {
+		return err
+	}
+
+	test, err := engleGrangerTest(assetPrices, hedgePrices)
+	if err != nil {
+		return err
+	}
+	printEngleGranger(printer, pair.asset.Secid, pair.hedge.Secid, test)
+
+	johansen, err := johansenTest([][]float64{assetPrices, hedgePrices}, command.JohansenLags)
+	if err != nil {
+		return err
+	}
+	printJohansen(printer, johansen)
+	return nil
+}
+
+// ////////////////////////////////////////////////////////
+// Get prices of the pair on common trading dates, close
+// or settlement prices are taken according to the spec
+// ////////////////////////////////////////////////////////
+func pairedPrices(pair hedgePair, spec returnSpec) ([]string, []float64, []float64) {
+	assetHistory, hedgeHistory := alignHistories(pair.assetHistory, pair.hedgeHistory)
+	assetBars := resampleHistory(assetHistory, spec)
+	hedgeBars := resampleHistory(hedgeHistory, spec)
+
+	var dates []string
+	var assetPrices, hedgePrices []float64
+	for idx := range assetBars {
+		if assetBars[idx].close == 0 || hedgeBars[idx].close == 0 {
+			continue
+		}
+		dates = append(dates, assetBars[idx].date)
+		assetPrices = append(assetPrices, assetBars[idx].close)
+		hedgePrices = append(hedgePrices, hedgeBars[idx].close)
+	}
+	return dates, assetPrices, hedgePrices
+}
+
+// ////////////////////////////////////////////////////////
+// Regress the asset prices on the hedge prices and test
+// residuals for a unit root: stationary residuals mean
+// that prices are cointegrated
+// ////////////////////////////////////////////////////////
+func engleGrangerTest(asset []float64, hedge []float64) (engleGranger, error) {
+	var result engleGranger
+	fit, err := leastSquares(asset, [][]float64{hedge}, true)
+	if err != nil {
+		return result, err
+	}
+	result.alpha, result.ratio = fit.coefficients[0], fit.coefficients[1]
+
+	result.adf, err = adfTest(fit.residuals, false)
+	if err != nil {
+		return result, err
+	}
+	for idx, surface := range engleGrangerSurface {
+		n := float64(result.adf.observations)
+		result.adf.critical[idx] = surface[0] + surface[1]/n + surface[2]/(n*n)
+	}
+
+	result.halfLife, err = spreadHalfLife(fit.residuals)
+	return result, err
+}
+
+// ////////////////////////////////////////////////////////
+// Augmented Dickey-Fuller regression of the series:
+// Δy[t] = (c) + γ y[t-1] + Σ φ[i] Δy[t-i], the number of
+// lagged differences is selected by AIC. Critical values
+// are left to the caller as they depend on the test
+// ////////////////////////////////////////////////////////
+func adfTest(series []float64, constant bool) (adfResult, error) {
+	maxLags := int(12 * math.Pow(float64(len(series))/100, 0.25))
+	if len(series)-maxLags-1 < maxLags+3 {
+		return adfResult{}, fmt.Errorf("%d observations are not enough for the unit root test", len(series))
+	}
+
+	differences := make([]float64, len(series)-1)
+	for idx := range differences {
+		differences[idx] = series[idx+1] - series[idx]
+	}
+
+	// All lag counts are compared on the same sample
+	var best adfResult
+	bestCriterion := math.Inf(1)
+	for lags := 0; lags <= maxLags; lags++ {
+		start := maxLags
+		target := differences[start:]
+		columns := [][]float64{series[start : len(series)-1]}
+		for lag := 1; lag <= lags; lag++ {
+			columns = append(columns, differences[start-lag:len(differences)-lag])
+		}
+
+		fit, err := leastSquares(target, columns, constant)
+		if err != nil {
+			return adfResult{}, err
+		}
+		n := float64(len(target))
+		criterion := n*math.Log(fit.rss/n) + 2*float64(len(fit.coefficients))
+		if criterion < bestCriterion {
+			bestCriterion = criterion
+			gamma := len(fit.coefficients) - len(columns)
+			best = adfResult{
+				statistic:    fit.coefficients[gamma] / fit.stdErrors[gamma],
+				lags:         lags,
+				observations: len(target),
+			}
+		}
+	}
+	return best, nil
+}
+
+// ////////////////////////////////////////////////////////
+// Half-life of mean reversion of the spread from AR(1)
+// regression Δs[t] = a + b s[t-1]
+// ////////////////////////////////////////////////////////
+func spreadHalfLife(spread []float64) (float64, error) {
+	differences := make([]float64, len(spread)-1)
+	for idx := range differences {
+		differences[idx] = spread[idx+1] - spread[idx]
+	}
+	fit, err := leastSquares(differences, [][]float64{spread[:len(spread)-1]}, true)
+	if err != nil {
+		return 0, err
+	}
+
+	slope := fit.coefficients[1]
+	if slope >= 0 || slope <= -1 {
+		return math.Inf(1), nil
+	}
+	return -math.Ln2 / math.Log(1+slope), nil
+}
+
+// Result of ordinary least squares
+type leastSquaresFit struct {
+	coefficients []float64 // intercept goes first if requested
+	stdErrors    []float64
+	residuals    []float64
+	rss          float64 // residual sum of squares
+}
+
+// ////////////////////////////////////////////////////////
+// Ordinary least squares regression of the target on the
+// columns, optionally with intercept
+// ////////////////////////////////////////////////////////
+func leastSquares(target []float64, columns [][]float64, intercept bool) (leastSquaresFit, error) {
+	var result leastSquaresFit
+	regressors := len(columns)
+	if intercept {
+		regressors++
+	}
+	if len(target) <= regressors {
+		return result, fmt.Errorf("%d observations are not enough to estimate %d coefficients", len(target), regressors)
+	}
+
+	design := mat.NewDense(len(target), regressors, nil)
+	for row := range target {
+		col := 0
+		if intercept {
+			design.Set(row, 0, 1)
+			col = 1
+		}
+		for _, column := range columns {
+			design.Set(row, col, column[row])
+			col++
+		}
+	}
+	y := mat.NewVecDense(len(target), append([]float64(nil), target...))
+
+	var coefficients, fitted, residuals mat.VecDense
+	if err := coefficients.SolveVec(design, y); err != nil {
+		return result, fmt.Errorf("failed to solve regression: %s", err)
+	}
+	fitted.MulVec(design, &coefficients)
+	residuals.SubVec(y, &fitted)
+
+	var covariance mat.Dense
+	covariance.Mul(design.T(), design)
+	if err := covariance.Inverse(&covariance); err != nil {
+		return result, fmt.Errorf("regressors are collinear: %s", err)
+	}
+
+	result.rss = mat.Dot(&residuals, &residuals)
+	variance := result.rss / float64(len(target)-regressors)
+	for idx := 0; idx < regressors; idx++ {
+		result.coefficients = append(result.coefficients, coefficients.AtVec(idx))
+		result.stdErrors = append(result.stdErrors, math.Sqrt(variance*covariance.At(idx, idx)))
+	}
+	result.residuals = residuals.RawVector().Data
+	return result, nil
+}
+
+// ////////////////////////////////////////////////////////
+// Whether the statistic rejects the null hypothesis at the
+// significance level: test statistics are left-tailed
+// ////////////////////////////////////////////////////////
+func (test adfResult) rejects(level int) bool {
+	return test.statistic < test.critical[level]
+}
+
+// ////////////////////////////////////////////////////////
+// Print results of Engle-Granger test
+// ////////////////////////////////////////////////////////
+func printEngleGranger(printer *message.Printer, asset string, hedge string, test engleGranger) {
+	printer.Printf("Engle-Granger test: %s = %f + %f * %s + spread\n", asset, test.alpha, test.ratio, hedge)
+	printer.Printf("\tADF statistic of the spread %f (%d lags, %d observations)\n", test.adf.statistic, test.adf.lags, test.adf.observations)
+	for idx, level := range criticalLevels {
+		verdict := "not cointegrated"
+		if test.adf.rejects(idx) {
+			verdict = "cointegrated"
+		}
+		printer.Printf("\t\tcritical value at %-3s %f: %s\n", level, test.adf.critical[idx], verdict)
+	}
+	if math.IsInf(test.halfLife, 1) {
+		printer.Printf("\tspread does not revert to the mean\n")
+	} else {
+		printer.Printf("\thalf-life of the spread is %.1f observations\n", test.halfLife)
+	}
+}
@@ -0,0 +1,113 @@
+package hedging
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Hedge follows a random walk, asset = 10 + 2 * hedge + AR(1) spread
+func cointegratedPair(seed int64, phi float64) ([]float64, []float64) {
+	random := rand.New(rand.NewSource(seed))
+	asset := make([]float64, 500)
+	hedge := make([]float64, 500)
+	price, spread := 100.0, 0.0
+	for idx := range asset {
+		price += random.NormFloat64()
+		spread = phi*spread + random.NormFloat64()
+		hedge[idx] = price
+		asset[idx] = 10 + 2*price + spread
+	}
+	return asset, hedge
+}
+
+// Two independent random walks
+func independentPair(seed int64) ([]float64, []float64) {
+	random := rand.New(rand.NewSource(seed))
+	asset := make([]float64, 500)
+	hedge := make([]float64, 500)
+	a, b := 100.0, 100.0
+	for idx := range asset {
+		a += random.NormFloat64()
+		b += random.NormFloat64()
+		asset[idx], hedge[idx] = a, b
+	}
+	return asset, hedge
+}
+
+func TestLeastSquares(t *testing.T) {
+	fit, err := leastSquares([]float64{3, 5, 7, 9.5}, [][]float64{{1, 2, 3, 4}}, true)
+	assert.NoError(t, err)
+	assert.InDelta(t, 2.15, fit.coefficients[1], 1e-9)
+	assert.InDelta(t, 0.75, fit.coefficients[0], 1e-9)
+	assert.Len(t, fit.residuals, 4)
+	assert.Greater(t, fit.stdErrors[1], 0.0)
+
+	_, err = leastSquares([]float64{1}, [][]float64{{1}}, true)
+	assert.Error(t, err)
+}
+
+func TestEngleGrangerCointegrated(t *testing.T) {
+	asset, hedge := cointegratedPair(1, 0.5)
+	test, err := engleGrangerTest(asset, hedge)
+	assert.NoError(t, err)
+	assert.InDelta(t, 2.0, test.ratio, 0.02)
+	assert.True(t, test.adf.rejects(0))
+	assert.InDelta(t, -3.9, test.adf.critical[0], 0.05)
+	assert.InDelta(t, 1.0, test.halfLife, 0.3)
+}
+
+func TestEngleGrangerIndependent(t *testing.T) {
+	asset, hedge := independentPair(2)
+	test, err := engleGrangerTest(asset, hedge)
+	assert.NoError(t, err)
+	assert.False(t, test.adf.rejects(0))
+}
+
+func TestSpreadHalfLife(t *testing.T) {
+	random := rand.New(rand.NewSource(3))
+	spread := make([]float64, 5000)
+	for idx := 1; idx < len(spread); idx++ {
+		spread[idx] = 0.9*spread[idx-1] + random.NormFloat64()
+	}
+	halfLife, err := spreadHalfLife(spread)
+	assert.NoError(t, err)
+	assert.InDelta(t, math.Ln2/-math.Log(0.9), halfLife, 1)
+
+	trending := []float64{1, 2, 4, 8, 16, 32}
+	halfLife, err = spreadHalfLife(trending)
+	assert.NoError(t, err)
+	assert.True(t, math.IsInf(halfLife, 1))
+}
+
+func TestJohansen(t *testing.T) {
+	asset, hedge := cointegratedPair(4, 0.5)
+	test, err := johansenTest([][]float64{asset, hedge}, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, test.rank(1))
+	assert.InDelta(t, 2.0, test.ratio, 0.05)
+	assert.Greater(t, test.eigenvalues[0], test.eigenvalues[1])
+
+	asset, hedge = independentPair(5)
+	test, err = johansenTest([][]float64{asset, hedge}, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, test.rank(1))
+
+	_, err = johansenTest([][]float64{asset}, 1)
+	assert.Error(t, err)
+}
+
+func TestCointWithMissingHedge(t *testing.T) {
+	calculator := &cointCalculator{}
+	err := calculator.Execute(Command{Asset: "SBER"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "hedge asset was not specified")
+}
+
+func TestCointRejectsNegativeJohansenLags(t *testing.T) {
+	calculator := &cointCalculator{}
+	err := calculator.Execute(Command{Hedge: "SiZ4", JohansenLags: -1})
+	assert.EqualError(t, err, "number of lagged differences of Johansen test must not be negative")
+}
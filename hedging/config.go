@@ -0,0 +1,90 @@
+package hedging
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// Environment variable overriding the cache DSN
+const CacheEnvVariable = "HEDGING_CACHE"
+
+// Settings read from the configuration file
+type Config struct {
+	Cache string `json:"cache"` // DSN of the cache, see parseDSN
+}
+
+// ///////////////////////////////////////////////////////////////////
+// Get the default location of the configuration file
+// ///////////////////////////////////////////////////////////////////
+func defaultConfigFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "hedging", "config.json"), nil
+}
+
+// ///////////////////////////////////////////////////////////////////
+// Read configuration from the JSON file (the default one if name is
+// empty). Missing file is not an error
+// ///////////////////////////////////////////////////////////////////
+func LoadConfig(filename string) (Config, error) {
+	var config Config
+
+	if len(filename) == 0 {
+		var err error
+		if filename, err = defaultConfigFile(); err != nil {
+			return config, err
+		}
+	}
+
+	content, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		slog.Debug(fmt.Sprintf("configuration file %s not found, using defaults", filename))
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+
+	if err = json.Unmarshal(content, &config); err != nil {
+		return config, fmt.Errorf("failed to parse configuration file %s: %s", filename, err)
+	}
+	return config, nil
+}
+
+// ///////////////////////////////////////////////////////////////////
+// Choose the cache DSN: command line option, then environment variable,
+// then configuration file and finally the file in user cache directory
+// ///////////////////////////////////////////////////////////////////
+func ResolveCacheDSN(option string, config Config) (string, error) {
+	cacheDSN := option
+	if len(cacheDSN) == 0 {
+		cacheDSN = os.Getenv(CacheEnvVariable)
+	}
+	if len(cacheDSN) == 0 {
+		cacheDSN = config.Cache
+	}
+	if len(cacheDSN) == 0 {
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to determine user cache directory: %s", err)
+		}
+		cacheDSN = filepath.Join(dir, "hedging", "cache.db")
+	}
+
+	backend, location := parseDSN(cacheDSN)
+	if backend == sqliteBackend {
+		if err := os.MkdirAll(filepath.Dir(location), 0o755); err != nil {
+			return "", err
+		}
+	}
+
+	slog.Debug(fmt.Sprintf("using cache %s", redactDSN(cacheDSN)))
+	return cacheDSN, nil
+}
@@ -0,0 +1,56 @@
+package hedging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	config, err := LoadConfig(filepath.Join(dir, "missing.json"))
+	assert.NoError(t, err)
+	assert.Empty(t, config.Cache)
+
+	filename := filepath.Join(dir, "config.json")
+	assert.NoError(t, os.WriteFile(filename, []byte(`{"cache": "/data/hedging.db"}`), 0o644))
+	config, err = LoadConfig(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "/data/hedging.db", config.Cache)
+
+	assert.NoError(t, os.WriteFile(filename, []byte(`{cache`), 0o644))
+	_, err = LoadConfig(filename)
+	assert.Error(t, err)
+}
+
+func TestResolveCacheDSN(t *testing.T) {
+	dir := t.TempDir()
+	option := filepath.Join(dir, "option", "cache.db")
+	env := filepath.Join(dir, "env", "cache.db")
+	config := Config{Cache: filepath.Join(dir, "config", "cache.db")}
+
+	t.Setenv(CacheEnvVariable, env)
+	cacheDSN, err := ResolveCacheDSN(option, config)
+	assert.NoError(t, err)
+	assert.Equal(t, option, cacheDSN)
+	assert.DirExists(t, filepath.Dir(option))
+
+	cacheDSN, err = ResolveCacheDSN("", config)
+	assert.NoError(t, err)
+	assert.Equal(t, env, cacheDSN)
+
+	t.Setenv(CacheEnvVariable, "")
+	cacheDSN, err = ResolveCacheDSN("", config)
+	assert.NoError(t, err)
+	assert.Equal(t, config.Cache, cacheDSN)
+
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(dir, "xdg"))
+	t.Setenv("HOME", dir)
+	cacheDSN, err = ResolveCacheDSN("", Config{})
+	assert.NoError(t, err)
+	assert.Equal(t, "cache.db", filepath.Base(cacheDSN))
+	assert.Equal(t, "hedging", filepath.Base(filepath.Dir(cacheDSN)))
+}
@@ -0,0 +1,127 @@
+package hedging
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/TuliMyrskyTaivas/hedging/moex"
+	"golang.org/x/text/message"
+)
+
+// Units of the position size
+const (
+	sharesPosition   = "shares"
+	notionalPosition = "rub"
+)
+
+// Hedge of the position by whole futures contracts
+type contractHedge struct {
+	positionValue  float64 // RUB value of the hedged position
+	contractValue  float64 // RUB value of a single contract
+	lotVolume      int     // units of the underlying asset per contract
+	exactContracts float64 // contracts needed by the hedge ratio, negative to sell
+	contracts      int     // contracts rounded to the nearest whole number
+	hedgedValue    float64 // RUB value of the rounded contracts
+}
+
+// ////////////////////////////////////////////////////////
+// Check the position size and its units
+// ////////////////////////////////////////////////////////
+func validatePosition(command Command) error {
+	if command.PositionSize < 0 {
+		return fmt.Errorf("position size must not be negative, use negative hedge ratio for short positions")
+	}
+	if command.PositionUnits != "" && command.PositionUnits != sharesPosition && command.PositionUnits != notionalPosition {
+		return fmt.Errorf("unknown position units %s, use %s or %s", command.PositionUnits, sharesPosition, notionalPosition)
+	}
+	return nil
+}
+
+// ////////////////////////////////////////////////////////
+// Get the last known price of the history
+// ////////////////////////////////////////////////////////
+func lastPrice(history []moex.HistoryItem) float64 {
+	for idx := len(history) - 1; idx >= 0; idx-- {
+		if history[idx].Close != 0 {
+			return history[idx].Close
+		}
+	}
+	return 0
+}
+
+// ////////////////////////////////////////////////////////
+// Get the last price of the future in points, falling back
+// to the previous settlement price
+// ////////////////////////////////////////////////////////
+func futurePrice(history []moex.HistoryItem, info moex.FutureInfo) float64 {
+	if price := lastPrice(history); price != 0 {
+		return price
+	}
+	return info.Prevsettleprice
+}
+
+// ////////////////////////////////////////////////////////
+// Get RUB value of a single futures contract: the price is
+// quoted in points, each MINSTEP of it costs STEPPRICE RUB
+// ////////////////////////////////////////////////////////
+func contractValue(price float64, info moex.FutureInfo) (float64, error) {
+	if info.Minstep == 0 || info.Stepprice == 0 {
+		return 0, fmt.Errorf("MOEX reports no step price of %s", info.Secid)
+	}
+	if price == 0 {
+		return 0, fmt.Errorf("no price of %s is known", info.Secid)
+	}
+	return price / info.Minstep * info.Stepprice, nil
+}
+
+// ////////////////////////////////////////////////////////
+// Get RUB value of the position
+// ////////////////////////////////////////////////////////
+func positionValue(command Command, price float64) float64 {
+	if command.PositionUnits == notionalPosition {
+		return command.PositionSize
+	}
+	return command.PositionSize * price
+}
+
+// ////////////////////////////////////////////////////////
+// Translate the hedge ratio of values into the number of
+// contracts: a long position is hedged by selling them
+// ////////////////////////////////////////////////////////
+func sizeHedge(ratio float64, position float64, info moex.FutureInfo, contract float64) contractHedge {
+	exact := -ratio * position / contract
+	contracts := int(math.Round(exact))
+	return contractHedge{
+		positionValue:  position,
+		contractValue:  contract,
+		lotVolume:      info.Lotvolume,
+		exactContracts: exact,
+		contracts:      contracts,
+		hedgedValue:    float64(contracts) * contract,
+	}
+}
+
+// ////////////////////////////////////////////////////////
+// Print the number of contracts and the rounding residual
+// ////////////////////////////////////////////////////////
+func printContractHedge(printer *message.Printer, future string, hedge contractHedge) {
+	action := "buy"
+	if hedge.contracts < 0 {
+		action = "sell"
+	}
+	printer.Printf("Position value is %.2f RUB, contract of %s is worth %.2f RUB (%d units of the underlying)\n",
+		hedge.positionValue, future, hedge.contractValue, hedge.lotVolume)
+	printer.Printf("To hedge the position %s %d contracts of %s (exactly %.3f, rounding residual %.3f contracts)\n",
+		action, abs(hedge.contracts), future, math.Abs(hedge.exactContracts), hedge.exactContracts-float64(hedge.contracts))
+	if hedge.positionValue != 0 {
+		printer.Printf("Hedged notional is %.2f RUB, effective hedge ratio %f\n",
+			math.Abs(hedge.hedgedValue), -hedge.hedgedValue/hedge.positionValue)
+	}
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
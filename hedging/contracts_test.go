@@ -0,0 +1,59 @@
+package hedging
+
+import (
+	"testing"
+
+	"github.com/TuliMyrskyTaivas/hedging/moex"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatePosition(t *testing.T) {
+	assert.NoError(t, validatePosition(Command{}))
+	assert.NoError(t, validatePosition(Command{PositionSize: 1000, PositionUnits: "rub"}))
+	assert.Error(t, validatePosition(Command{PositionSize: -1}))
+	assert.Error(t, validatePosition(Command{PositionSize: 1, PositionUnits: "lots"}))
+}
+
+func TestLastPrice(t *testing.T) {
+	history := []moex.HistoryItem{{Close: 100}, {Close: 101}, {Close: 0}}
+	assert.Equal(t, 101.0, lastPrice(history))
+	assert.Equal(t, 0.0, lastPrice(nil))
+
+	info := moex.FutureInfo{Prevsettleprice: 99}
+	assert.Equal(t, 101.0, futurePrice(history, info))
+	assert.Equal(t, 99.0, futurePrice(nil, info))
+}
+
+func TestContractValue(t *testing.T) {
+	// Si: 1000 USD per contract, price in RUB per contract, step 1 point costs 1 RUB
+	si := moex.FutureInfo{Secid: "SiZ4", Minstep: 1, Stepprice: 1, Lotvolume: 1000}
+	value, err := contractValue(92000, si)
+	assert.NoError(t, err)
+	assert.Equal(t, 92000.0, value)
+
+	// RTS: price in points, step 10 points costs about 9 RUB
+	rts := moex.FutureInfo{Secid: "RIZ4", Minstep: 10, Stepprice: 9.2, Prevsettleprice: 100000}
+	value, err = contractValue(futurePrice(nil, rts), rts)
+	assert.NoError(t, err)
+	assert.InDelta(t, 92000.0, value, 1e-9)
+
+	_, err = contractValue(100, moex.FutureInfo{Secid: "X"})
+	assert.Error(t, err)
+	_, err = contractValue(0, moex.FutureInfo{Secid: "X", Minstep: 1, Stepprice: 1})
+	assert.Error(t, err)
+}
+
+func TestSizeHedge(t *testing.T) {
+	info := moex.FutureInfo{Secid: "SRZ4", Minstep: 1, Stepprice: 1, Lotvolume: 100}
+	position := positionValue(Command{PositionSize: 10000}, 250)
+	assert.Equal(t, 2500000.0, position)
+
+	hedge := sizeHedge(0.9, position, info, 25500)
+	assert.InDelta(t, -88.235, hedge.exactContracts, 1e-3)
+	assert.Equal(t, -88, hedge.contracts)
+	assert.Equal(t, -88*25500.0, hedge.hedgedValue)
+	assert.Equal(t, 100, hedge.lotVolume)
+
+	position = positionValue(Command{PositionSize: 1000000, PositionUnits: "rub"}, 250)
+	assert.Equal(t, 1000000.0, position)
+}
@@ -0,0 +1,201 @@
+package hedging
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/TuliMyrskyTaivas/hedging/moex"
+	"golang.org/x/text/message"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat"
+)
+
+// Minimum variance hedge of the asset by several instruments
+type crossHedge struct {
+	ratios        []float64 // value of each hedge position per unit of the asset position value
+	efficiency    float64   // share of the asset variance removed by all instruments
+	contributions []float64 // loss of efficiency if the instrument is dropped
+}
+
+// ////////////////////////////////////////////////////////
+// Hedge the asset by several instruments at once
+// ////////////////////////////////////////////////////////
+func (calculator *hedgeCalculator) executeCrossHedge(command Command, resolver *assetResolver, hedgeNames []string,
+	spec returnSpec, cleaning cleaningSpec, levels []float64) error {
+	if len(command.Asset) == 0 {
+		return fmt.Errorf("asset must be specified to hedge it by several instruments")
+	}
+
+	asset, err := resolver.GetAsset(command.Asset)
+	if err != nil {
+		return err
+	}
+	hedges := make([]moex.Asset, len(hedgeNames))
+	for idx, name := range hedgeNames {
+		if hedges[idx], err = resolver.GetAsset(name); err != nil {
+			return err
+		}
+	}
+
+	historyFrom, historyTo := historyRange(command.HistoryDepth, append([]moex.Asset{asset}, hedges...)...)
+	assetHistory, err := asset.GetHistory(historyFrom, historyTo)
+	if err != nil {
+		return err
+	}
+	hedgeHistories := make([][]moex.HistoryItem, len(hedges))
+	for idx, hedge := range hedges {
+		if hedgeHistories[idx], err = hedge.GetHistory(historyFrom, historyTo); err != nil {
+			return err
+		}
+		assetHistory, hedgeHistories[idx] = alignHistories(assetHistory, hedgeHistories[idx])
+	}
+
+	// Returns of the asset go first, then returns of the hedges
+	tickers := []string{asset.Secid}
+	var dates [][]string
+	var series [][]float64
+	assetDates, assetReturns := historyReturns(asset.Secid, assetHistory, spec)
+	dates, series = append(dates, assetDates), append(series, assetReturns)
+	for idx, hedge := range hedges {
+		_, hedgeHistories[idx] = alignHistories(assetHistory, hedgeHistories[idx])
+		hedgeDates, hedgeReturns := historyReturns(hedge.Secid, hedgeHistories[idx], spec)
+		tickers = append(tickers, hedge.Secid)
+		dates, series = append(dates, hedgeDates), append(series, hedgeReturns)
+	}
+
+	fmt.Printf("Hedge is estimated on %s taken with %s\n", spec, describeWeighting(decayFactor(command)))
+	commonDates, aligned := alignSeries(dates, series)
+	commonDates, aligned, cleaned := cleanSeries(cleaning, commonDates, tickers, aligned)
+	for _, summary := range cleaned {
+		fmt.Println(describeCleaning(cleaning, summary))
+	}
+
+	weights := ewmaWeights(len(commonDates), decayFactor(command))
+	result, err := crossHedgeRegression(aligned[0], aligned[1:], weights)
+	if err != nil {
+		return err
+	}
+
+	printer, err := GetPrinter()
+	if err != nil {
+		return err
+	}
+	printCrossHedge(printer, asset.Secid, hedgeNames, result)
+
+	if command.PositionSize > 0 {
+		position := positionValue(command, lastPrice(assetHistory))
+		for idx, hedge := range hedges {
+			info, err := resolver.GetFutureInfo(hedge.Secid)
+			if err != nil {
+				return fmt.Errorf("failed to get specification of %s, hedge must be a future to size it in contracts: %s", hedge.Secid, err)
+			}
+			price := futurePrice(hedgeHistories[idx], info)
+			contract, err := contractValue(price, info)
+			if err != nil {
+				return err
+			}
+			contracts := sizeHedge(result.ratios[idx], position, info, contract)
+			printContractHedge(printer, hedge.Secid, contracts)
+			daily := marginReturns(hedge.Secid, hedgeHistories[idx])
+			margin, err := estimateMargin(info, contracts.contracts, price, daily, ewmaWeights(len(daily), decayFactor(command)), levels)
+			if err != nil {
+				return err
+			}
+			printMarginEstimate(printer, hedge.Secid, margin)
+		}
+	}
+	return nil
+}
+
+// ////////////////////////////////////////////////////////
+// Solve the multivariate minimum variance problem: hedge
+// ratios are the slopes of the asset returns regressed on
+// returns of all instruments
+// ////////////////////////////////////////////////////////
+func crossHedgeRegression(y []float64, xs [][]float64, weights []float64) (crossHedge, error) {
+	ratios, efficiency, err := multipleRegression(y, xs, weights)
+	if err != nil {
+		return crossHedge{}, err
+	}
+
+	result := crossHedge{ratios: ratios, efficiency: efficiency}
+	for idx := range xs {
+		var others [][]float64
+		others = append(others, xs[:idx]...)
+		others = append(others, xs[idx+1:]...)
+		_, reduced, err := multipleRegression(y, others, weights)
+		if err != nil {
+			return crossHedge{}, err
+		}
+		result.contributions = append(result.contributions, efficiency-reduced)
+	}
+	return result, nil
+}
+
+// ////////////////////////////////////////////////////////
+// Weighted least squares regression of y on several
+// regressors with intercept, returns the slopes and R²
+// ////////////////////////////////////////////////////////
+func multipleRegression(y []float64, xs [][]float64, weights []float64) ([]float64, float64, error) {
+	if len(xs) == 0 {
+		return nil, 0, nil
+	}
+	if len(y) <= len(xs)+1 {
+		return nil, 0, fmt.Errorf("%d observations are not enough to regress on %d instruments", len(y), len(xs))
+	}
+
+	// Rows are scaled by square roots of weights to get ordinary least squares
+	design := mat.NewDense(len(y), len(xs)+1, nil)
+	target := mat.NewVecDense(len(y), nil)
+	for row := range y {
+		scale := sqrtWeight(weights, row)
+		design.Set(row, 0, scale)
+		for col, x := range xs {
+			design.Set(row, col+1, scale*x[row])
+		}
+		target.SetVec(row, scale*y[row])
+	}
+
+	var coefficients mat.VecDense
+	if err := coefficients.SolveVec(design, target); err != nil {
+		return nil, 0, fmt.Errorf("returns of hedge instruments are collinear: %s", err)
+	}
+
+	var fitted, residuals mat.VecDense
+	fitted.MulVec(design, &coefficients)
+	residuals.SubVec(target, &fitted)
+
+	mean := stat.Mean(y, weights)
+	var total float64
+	for row := range y {
+		total += weightAt(weights, row) * (y[row] - mean) * (y[row] - mean)
+	}
+
+	slopes := make([]float64, len(xs))
+	for idx := range slopes {
+		slopes[idx] = coefficients.AtVec(idx + 1)
+	}
+	return slopes, 1 - mat.Dot(&residuals, &residuals)/total, nil
+}
+
+// ////////////////////////////////////////////////////////
+// Square root of the observation weight
+// ////////////////////////////////////////////////////////
+func sqrtWeight(weights []float64, idx int) float64 {
+	if weights == nil {
+		return 1
+	}
+	return math.Sqrt(weights[idx])
+}
+
+// ////////////////////////////////////////////////////////
+// Print hedge ratios and contributions of instruments
+// ////////////////////////////////////////////////////////
+func printCrossHedge(printer *message.Printer, asset string, hedges []string, result crossHedge) {
+	printer.Printf("Minimum variance hedge of %s:\n", asset)
+	printer.Printf("\t%-12s %12s %14s\n", "Instrument", "Hedge ratio", "Contribution")
+	for idx, hedge := range hedges {
+		printer.Printf("\t%-12s %12f %14f\n", hedge, result.ratios[idx], result.contributions[idx])
+	}
+	printer.Printf("Joint hedging efficiency is %f\n", result.efficiency)
+}
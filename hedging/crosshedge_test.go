@@ -0,0 +1,59 @@
+package hedging
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/stat"
+)
+
+func TestCrossHedgeRegression(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	first := make([]float64, 300)
+	second := make([]float64, 300)
+	asset := make([]float64, 300)
+	for idx := range asset {
+		first[idx] = random.NormFloat64() * 0.02
+		second[idx] = random.NormFloat64() * 0.01
+		asset[idx] = 0.5*first[idx] + 0.3*second[idx] + random.NormFloat64()*0.001
+	}
+
+	result, err := crossHedgeRegression(asset, [][]float64{first, second}, nil)
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.5, result.ratios[0], 0.01)
+	assert.InDelta(t, 0.3, result.ratios[1], 0.02)
+	assert.Greater(t, result.efficiency, 0.99)
+	assert.Greater(t, result.contributions[0], result.contributions[1])
+	assert.Greater(t, result.contributions[1], 0.0)
+
+	weighted, err := crossHedgeRegression(asset, [][]float64{first, second}, ewmaWeights(len(asset), 0.99))
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.5, weighted.ratios[0], 0.01)
+}
+
+func TestSingleInstrumentMatchesOptimalHedge(t *testing.T) {
+	hedge := []float64{0.01, -0.02, 0.015, 0.003, -0.007, 0.012}
+	asset := []float64{0.02, -0.025, 0.01, 0.001, -0.012, 0.02}
+
+	result, err := crossHedgeRegression(asset, [][]float64{hedge}, nil)
+	assert.NoError(t, err)
+	correlation := stat.Correlation(hedge, asset, nil)
+	assert.InDelta(t, correlation*stat.StdDev(asset, nil)/stat.StdDev(hedge, nil), result.ratios[0], 1e-9)
+	assert.InDelta(t, correlation*correlation, result.efficiency, 1e-9)
+	assert.InDelta(t, result.efficiency, result.contributions[0], 1e-9)
+}
+
+func TestMultipleRegressionNeedsObservations(t *testing.T) {
+	_, _, err := multipleRegression([]float64{1, 2, 3}, [][]float64{{1, 2, 3}, {3, 1, 2}}, nil)
+	assert.Error(t, err)
+}
+
+func TestAlignSeries(t *testing.T) {
+	dates := [][]string{{"2024-01-02", "2024-01-03", "2024-01-04"}, {"2024-01-03", "2024-01-04"}, {"2024-01-02", "2024-01-04"}}
+	values := [][]float64{{1, 2, 3}, {4, 5}, {6, 7}}
+
+	common, aligned := alignSeries(dates, values)
+	assert.Equal(t, []string{"2024-01-04"}, common)
+	assert.Equal(t, [][]float64{{3}, {5}, {7}}, aligned)
+}
@@ -0,0 +1,288 @@
+package hedging
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+
+	"golang.org/x/text/message"
+	"gonum.org/v1/gonum/optimize"
+	"gonum.org/v1/gonum/stat"
+)
+
+// Minimal number of returns to estimate GARCH model on
+const minGarchObservations = 100
+
+// Univariate GARCH(1,1) model of returns
+type garchFit struct {
+	omega     float64   // constant of the variance equation
+	alpha     float64   // reaction of the variance to the last squared shock
+	beta      float64   // persistence of the variance
+	variances []float64 // conditional variance of every return
+	forecast  float64   // variance of the next return
+}
+
+// DCC(1,1) model of the correlation of standardized residuals
+type dccFit struct {
+	a            float64   // reaction of the correlation to the last shocks
+	b            float64   // persistence of the correlation
+	correlations []float64 // conditional correlation of every pair of returns
+	forecast     float64   // correlation of the next pair of returns
+}
+
+// Hedge ratio changing with conditional volatilities and correlation
+type dynamicHedge struct {
+	asset       garchFit
+	hedge       garchFit
+	correlation dccFit
+	ratios      []float64 // conditional hedge ratio of every period
+	current     float64   // hedge ratio recommended for the next period
+}
+
+// ////////////////////////////////////////////////////////
+// Estimate DCC-GARCH(1,1) model of the pair of returns and
+// conditional minimum variance hedge ratio
+// h[t] = ρ[t] σa[t] / σh[t]
+// ////////////////////////////////////////////////////////
+func dccHedge(asset []float64, hedge []float64) (dynamicHedge, error) {
+	var result dynamicHedge
+	if len(asset) != len(hedge) {
+		return result, fmt.Errorf("series of different length: %d and %d", len(asset), len(hedge))
+	}
+
+	var err error
+	if result.asset, err = garch(asset); err != nil {
+		return result, fmt.Errorf("failed to estimate GARCH model of the asset: %s", err)
+	}
+	if result.hedge, err = garch(hedge); err != nil {
+		return result, fmt.Errorf("failed to estimate GARCH model of the hedge: %s", err)
+	}
+	assetResiduals := standardizedResiduals(asset, result.asset.variances)
+	hedgeResiduals := standardizedResiduals(hedge, result.hedge.variances)
+	if result.correlation, err = dcc(assetResiduals, hedgeResiduals); err != nil {
+		return result, fmt.Errorf("failed to estimate DCC model: %s", err)
+	}
+
+	result.ratios = make([]float64, len(asset))
+	for idx := range asset {
+		result.ratios[idx] = result.correlation.correlations[idx] *
+			math.Sqrt(result.asset.variances[idx]/result.hedge.variances[idx])
+	}
+	result.current = result.correlation.forecast * math.Sqrt(result.asset.forecast/result.hedge.forecast)
+	return result, nil
+}
+
+// ////////////////////////////////////////////////////////
+// Estimate GARCH(1,1) by maximum likelihood with variance
+// targeting: σ²[t] = ω + α ε²[t-1] + β σ²[t-1], where
+// ω = v(1 - α - β) keeps the sample variance v
+// ////////////////////////////////////////////////////////
+func garch(returns []float64) (garchFit, error) {
+	var result garchFit
+	if len(returns) < minGarchObservations {
+		return result, fmt.Errorf("at least %d returns are required, got %d", minGarchObservations, len(returns))
+	}
+
+	mean, variance := stat.MeanVariance(returns, nil)
+	if variance == 0 {
+		return result, fmt.Errorf("returns have zero variance")
+	}
+	shocks := make([]float64, len(returns))
+	for idx, value := range returns {
+		shocks[idx] = value - mean
+	}
+
+	likelihood := func(alpha float64, beta float64) float64 {
+		omega := variance * (1 - alpha - beta)
+		sum := 0.0
+		conditional := variance
+		for idx, shock := range shocks {
+			if idx > 0 {
+				conditional = omega + alpha*shocks[idx-1]*shocks[idx-1] + beta*conditional
+			}
+			sum += math.Log(conditional) + shock*shock/conditional
+		}
+		return sum / 2
+	}
+
+	alpha, beta, err := maximizePersistent(likelihood, 0.05, 0.90)
+	if err != nil {
+		return result, err
+	}
+
+	result.alpha, result.beta = alpha, beta
+	result.omega = variance * (1 - alpha - beta)
+	result.variances = make([]float64, len(shocks))
+	result.variances[0] = variance
+	for idx := 1; idx < len(shocks); idx++ {
+		result.variances[idx] = result.omega + alpha*shocks[idx-1]*shocks[idx-1] + beta*result.variances[idx-1]
+	}
+	last := len(shocks) - 1
+	result.forecast = result.omega + alpha*shocks[last]*shocks[last] + beta*result.variances[last]
+	return result, nil
+}
+
+// ////////////////////////////////////////////////////////
+// Estimate DCC(1,1) of the standardized residuals by the
+// second stage likelihood of Engle (2002):
+// Q[t] = (1 - a - b) Q̄ + a z[t-1] z'[t-1] + b Q[t-1]
+// ////////////////////////////////////////////////////////
+func dcc(first []float64, second []float64) (dccFit, error) {
+	var result dccFit
+	n := len(first)
+	meanFirst, meanSecond := 0.0, 0.0
+	for idx := 0; idx < n; idx++ {
+		meanFirst += first[idx] * first[idx]
+		meanSecond += second[idx] * second[idx]
+	}
+	unconditional := [3]float64{meanFirst / float64(n), meanSecond / float64(n), 0}
+	for idx := 0; idx < n; idx++ {
+		unconditional[2] += first[idx] * second[idx] / float64(n)
+	}
+
+	// Correlation of every period by the recursion of Q
+	correlations := func(a float64, b float64, output []float64) float64 {
+		q := unconditional
+		for idx := 0; idx < n; idx++ {
+			if idx > 0 {
+				q[0] = (1-a-b)*unconditional[0] + a*first[idx-1]*first[idx-1] + b*q[0]
+				q[1] = (1-a-b)*unconditional[1] + a*second[idx-1]*second[idx-1] + b*q[1]
+				q[2] = (1-a-b)*unconditional[2] + a*first[idx-1]*second[idx-1] + b*q[2]
+			}
+			output[idx] = q[2] / math.Sqrt(q[0]*q[1])
+		}
+		q[0] = (1-a-b)*unconditional[0] + a*first[n-1]*first[n-1] + b*q[0]
+		q[1] = (1-a-b)*unconditional[1] + a*second[n-1]*second[n-1] + b*q[1]
+		q[2] = (1-a-b)*unconditional[2] + a*first[n-1]*second[n-1] + b*q[2]
+		return q[2] / math.Sqrt(q[0]*q[1])
+	}
+
+	series := make([]float64, n)
+	likelihood := func(a float64, b float64) float64 {
+		correlations(a, b, series)
+		sum := 0.0
+		for idx, rho := range series {
+			determinant := 1 - rho*rho
+			if determinant <= 0 {
+				return math.Inf(1)
+			}
+			x, y := first[idx], second[idx]
+			sum += math.Log(determinant) + (x*x+y*y-2*rho*x*y)/determinant - x*x - y*y
+		}
+		return sum / 2
+	}
+
+	a, b, err := maximizePersistent(likelihood, 0.05, 0.90)
+	if err != nil {
+		return result, err
+	}
+	result.a, result.b = a, b
+	result.correlations = make([]float64, n)
+	result.forecast = correlations(a, b, result.correlations)
+	return result, nil
+}
+
+// ////////////////////////////////////////////////////////
+// Minimize negative log-likelihood over a pair of
+// non-negative parameters with the sum below one. The
+// search runs on the logit of the sum and of the share of
+// the first parameter in it
+// ////////////////////////////////////////////////////////
+func maximizePersistent(negativeLikelihood func(float64, float64) float64, first float64, second float64) (float64, float64, error) {
+	unpack := func(x []float64) (float64, float64) {
+		persistence := logistic(x[0])
+		share := logistic(x[1])
+		return persistence * share, persistence * (1 - share)
+	}
+
+	problem := optimize.Problem{
+		Func: func(x []float64) float64 {
+			value := negativeLikelihood(unpack(x))
+			if math.IsNaN(value) {
+				return math.Inf(1)
+			}
+			return value
+		},
+	}
+	initial := []float64{logit(first + second), logit(first / (first + second))}
+	result, err := optimize.Minimize(problem, initial, nil, &optimize.NelderMead{})
+	if err != nil {
+		return 0, 0, fmt.Errorf("likelihood maximization failed: %s", err)
+	}
+	if math.IsInf(result.F, 0) {
+		return 0, 0, fmt.Errorf("likelihood is not finite")
+	}
+	first, second = unpack(result.X)
+	return first, second, nil
+}
+
+func logistic(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
+
+func logit(p float64) float64 {
+	return math.Log(p / (1 - p))
+}
+
+// ////////////////////////////////////////////////////////
+// Returns scaled by their conditional volatility
+// ////////////////////////////////////////////////////////
+func standardizedResiduals(returns []float64, variances []float64) []float64 {
+	mean := stat.Mean(returns, nil)
+	result := make([]float64, len(returns))
+	for idx, value := range returns {
+		result[idx] = (value - mean) / math.Sqrt(variances[idx])
+	}
+	return result
+}
+
+// ////////////////////////////////////////////////////////
+// Print parameters of the model and the dynamic hedge
+// ////////////////////////////////////////////////////////
+func printDynamicHedge(printer *message.Printer, asset string, hedge string, dates []string, model dynamicHedge, static float64) {
+	printer.Printf("DCC-GARCH(1,1) hedge ratio of %s on %s:\n", asset, hedge)
+	for _, fit := range []struct {
+		ticker string
+		garchFit
+	}{{asset, model.asset}, {hedge, model.hedge}} {
+		printer.Printf("\t%s GARCH ω %g, α %f, β %f, next day volatility %f\n", fit.ticker, fit.omega, fit.alpha, fit.beta, math.Sqrt(fit.forecast))
+	}
+	printer.Printf("\tDCC a %f, b %f, next day correlation %f\n", model.correlation.a, model.correlation.b, model.correlation.forecast)
+	printer.Printf("\tRecommended hedge ratio %f (static ratio %f)\n", model.current, static)
+	printer.Printf("\t%-10s %12s %12s %12s\n", "Date", "Correlation", "Hedge ratio", "Difference")
+	for idx, date := range dates {
+		printer.Printf("\t%-10s %12f %12f %12f\n", date, model.correlation.correlations[idx], model.ratios[idx], model.ratios[idx]-static)
+	}
+}
+
+// ////////////////////////////////////////////////////////
+// Write conditional volatilities, correlation and hedge
+// ratio to CSV file
+// ////////////////////////////////////////////////////////
+func exportDynamicHedge(filename string, dates []string, model dynamicHedge) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if err = writer.Write([]string{"date", "asset_volatility", "hedge_volatility", "correlation", "hedge_ratio"}); err != nil {
+		return err
+	}
+	format := func(value float64) string {
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+	for idx, date := range dates {
+		err = writer.Write([]string{date, format(math.Sqrt(model.asset.variances[idx])), format(math.Sqrt(model.hedge.variances[idx])),
+			format(model.correlation.correlations[idx]), format(model.ratios[idx])})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,128 @@
+package hedging
+
+import (
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/stat"
+)
+
+// Returns simulated by DCC-GARCH(1,1) with their true hedge ratios
+func dccPair(seed int64, n int) ([]float64, []float64, []float64) {
+	const (
+		alpha, beta = 0.08, 0.90
+		a, b        = 0.04, 0.94
+		rhoBar      = 0.6
+		variance    = 1e-4
+	)
+	random := rand.New(rand.NewSource(seed))
+	asset := make([]float64, n)
+	hedge := make([]float64, n)
+	ratios := make([]float64, n)
+
+	assetVariance, hedgeVariance := variance, 2*variance
+	q := [3]float64{1, 1, rhoBar}
+	var zAsset, zHedge float64
+	for idx := 0; idx < n; idx++ {
+		if idx > 0 {
+			assetVariance = variance*(1-alpha-beta) + alpha*asset[idx-1]*asset[idx-1] + beta*assetVariance
+			hedgeVariance = 2*variance*(1-alpha-beta) + alpha*hedge[idx-1]*hedge[idx-1] + beta*hedgeVariance
+			q[0] = (1 - a - b) + a*zAsset*zAsset + b*q[0]
+			q[1] = (1 - a - b) + a*zHedge*zHedge + b*q[1]
+			q[2] = (1-a-b)*rhoBar + a*zAsset*zHedge + b*q[2]
+		}
+		rho := q[2] / math.Sqrt(q[0]*q[1])
+		first, second := random.NormFloat64(), random.NormFloat64()
+		zAsset, zHedge = first, rho*first+math.Sqrt(1-rho*rho)*second
+		asset[idx] = math.Sqrt(assetVariance) * zAsset
+		hedge[idx] = math.Sqrt(hedgeVariance) * zHedge
+		ratios[idx] = rho * math.Sqrt(assetVariance/hedgeVariance)
+	}
+	return asset, hedge, ratios
+}
+
+func TestGarchRecoversParameters(t *testing.T) {
+	asset, _, _ := dccPair(1, 3000)
+	fit, err := garch(asset)
+	assert.Nil(t, err)
+	assert.InDelta(t, 0.08, fit.alpha, 0.04)
+	assert.InDelta(t, 0.90, fit.beta, 0.05)
+	assert.Len(t, fit.variances, len(asset))
+	assert.Greater(t, fit.forecast, 0.0)
+}
+
+func TestGarchTooShort(t *testing.T) {
+	_, err := garch(make([]float64, minGarchObservations-1))
+	assert.NotNil(t, err)
+}
+
+func TestGarchConstantReturns(t *testing.T) {
+	returns := make([]float64, minGarchObservations)
+	for idx := range returns {
+		returns[idx] = 0.01
+	}
+	_, err := garch(returns)
+	assert.NotNil(t, err)
+}
+
+func TestDccHedgeTracksTrueRatio(t *testing.T) {
+	asset, hedge, ratios := dccPair(2, 3000)
+	model, err := dccHedge(asset, hedge)
+	assert.Nil(t, err)
+	assert.InDelta(t, 0.04, model.correlation.a, 0.03)
+	assert.InDelta(t, 0.98, model.correlation.a+model.correlation.b, 0.03)
+	assert.Len(t, model.ratios, len(asset))
+
+	// The estimated path follows the true conditional hedge ratio
+	assert.Greater(t, stat.Correlation(model.ratios[100:], ratios[100:], nil), 0.9)
+	assert.InDelta(t, ratios[len(ratios)-1], model.current, 0.15)
+}
+
+func TestDccHedgeConstantCorrelation(t *testing.T) {
+	random := rand.New(rand.NewSource(3))
+	n := 1000
+	asset := make([]float64, n)
+	hedge := make([]float64, n)
+	for idx := range asset {
+		hedge[idx] = 0.02 * random.NormFloat64()
+		asset[idx] = 0.5*hedge[idx] + 0.005*random.NormFloat64()
+	}
+	model, err := dccHedge(asset, hedge)
+	assert.Nil(t, err)
+	assert.InDelta(t, 0.5, model.current, 0.05)
+	for _, ratio := range model.ratios {
+		assert.InDelta(t, 0.5, ratio, 0.1)
+	}
+}
+
+func TestDccHedgeDifferentLength(t *testing.T) {
+	_, err := dccHedge(make([]float64, 200), make([]float64, 199))
+	assert.NotNil(t, err)
+}
+
+func TestExportDynamicHedge(t *testing.T) {
+	model := dynamicHedge{
+		asset:       garchFit{variances: []float64{0.04}},
+		hedge:       garchFit{variances: []float64{0.01}},
+		correlation: dccFit{correlations: []float64{0.5}},
+		ratios:      []float64{1},
+	}
+	filename := filepath.Join(t.TempDir(), "dcc.csv")
+	if err := exportDynamicHedge(filename, []string{"2024-01-03"}, model); err != nil {
+		t.Fatalf("Failed to export dynamic hedge: %v", err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read export: %v", err)
+	}
+	expected := "date,asset_volatility,hedge_volatility,correlation,hedge_ratio\n2024-01-03,0.2,0.1,0.5,1\n"
+	if strings.TrimSpace(string(data)) != strings.TrimSpace(expected) {
+		t.Errorf("Unexpected export:\n%s", data)
+	}
+}
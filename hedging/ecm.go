@@ -0,0 +1,90 @@
+package hedging
+
+import (
+	"fmt"
+	"math"
+
+	"golang.org/x/text/message"
+	"gonum.org/v1/gonum/stat"
+)
+
+// Hedge ratio estimated by the error correction model
+type errorCorrection struct {
+	ratio        float64 // short-run hedge ratio of the asset changes on the hedge changes
+	adjustment   float64 // speed of the correction of the deviation from the long-run relationship
+	longRunRatio float64 // slope of the cointegrating regression
+}
+
+// ////////////////////////////////////////////////////////
+// Estimate Engle-Granger error correction model on log
+// prices: ΔS[t] = a + h ΔF[t] + γ z[t-1], where z is the
+// residual of the cointegrating regression S = α + β F
+// ////////////////////////////////////////////////////////
+func errorCorrectionModel(asset []float64, hedge []float64) (errorCorrection, error) {
+	var result errorCorrection
+	longRun, err := leastSquares(asset, [][]float64{hedge}, true)
+	if err != nil {
+		return result, err
+	}
+	result.longRunRatio = longRun.coefficients[1]
+
+	n := len(asset) - 1
+	assetChanges := make([]float64, n)
+	hedgeChanges := make([]float64, n)
+	for idx := 0; idx < n; idx++ {
+		assetChanges[idx] = asset[idx+1] - asset[idx]
+		hedgeChanges[idx] = hedge[idx+1] - hedge[idx]
+	}
+	shortRun, err := leastSquares(assetChanges, [][]float64{hedgeChanges, longRun.residuals[:n]}, true)
+	if err != nil {
+		return result, err
+	}
+	result.ratio, result.adjustment = shortRun.coefficients[1], shortRun.coefficients[2]
+	return result, nil
+}
+
+// ////////////////////////////////////////////////////////
+// Share of the asset variance removed by the hedge
+// ////////////////////////////////////////////////////////
+func hedgingEfficiency(asset []float64, hedge []float64, ratio float64, weights []float64) float64 {
+	hedged := make([]float64, len(asset))
+	for idx := range asset {
+		hedged[idx] = asset[idx] - ratio*hedge[idx]
+	}
+	return 1 - stat.Variance(hedged, weights)/stat.Variance(asset, weights)
+}
+
+// ////////////////////////////////////////////////////////
+// Log prices for the error correction model
+// ////////////////////////////////////////////////////////
+func logPrices(prices []float64) []float64 {
+	result := make([]float64, len(prices))
+	for idx, price := range prices {
+		result[idx] = math.Log(price)
+	}
+	return result
+}
+
+// ////////////////////////////////////////////////////////
+// Compare minimum variance and error correction hedges
+// on the returns the hedge was estimated on
+// ////////////////////////////////////////////////////////
+func printHedgeComparison(printer *message.Printer, minimumVariance float64, model errorCorrection, asset []float64,
+	hedge []float64, weights []float64) {
+	printer.Printf("\t%-18s %12s %12s\n", "Model", "Hedge ratio", "Efficiency")
+	printer.Printf("\t%-18s %12f %12f\n", "Minimum variance", minimumVariance, hedgingEfficiency(asset, hedge, minimumVariance, weights))
+	printer.Printf("\t%-18s %12f %12f\n", "Error correction", model.ratio, hedgingEfficiency(asset, hedge, model.ratio, weights))
+	printer.Printf("\tlong-run ratio %f, adjustment speed %f\n", model.longRunRatio, model.adjustment)
+}
+
+// ////////////////////////////////////////////////////////
+// Estimate error correction hedge on log prices of the pair
+// ////////////////////////////////////////////////////////
+func estimateErrorCorrection(pair hedgePair, spec returnSpec) (errorCorrection, error) {
+	_, assetPrices, hedgePrices := pairedPrices(pair, spec)
+	model, err := errorCorrectionModel(logPrices(assetPrices), logPrices(hedgePrices))
+	if err != nil {
+		return model, fmt.Errorf("failed to estimate error correction model: %s", err)
+	}
+	return model, nil
+}
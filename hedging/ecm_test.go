@@ -0,0 +1,36 @@
+package hedging
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/stat"
+)
+
+func TestErrorCorrectionModel(t *testing.T) {
+	asset, hedge := cointegratedPair(6, 0.5)
+	model, err := errorCorrectionModel(asset, hedge)
+	assert.NoError(t, err)
+	assert.InDelta(t, 2.0, model.ratio, 0.1)
+	assert.InDelta(t, 2.0, model.longRunRatio, 0.02)
+	assert.InDelta(t, -0.5, model.adjustment, 0.1)
+
+	_, err = errorCorrectionModel([]float64{1, 2}, []float64{1, 3})
+	assert.Error(t, err)
+}
+
+func TestHedgingEfficiency(t *testing.T) {
+	hedge := []float64{0.01, -0.02, 0.015, 0.003, -0.007, 0.012}
+	asset := []float64{0.02, -0.025, 0.01, 0.001, -0.012, 0.02}
+
+	correlation := stat.Correlation(hedge, asset, nil)
+	ratio := correlation * stat.StdDev(asset, nil) / stat.StdDev(hedge, nil)
+	assert.InDelta(t, correlation*correlation, hedgingEfficiency(asset, hedge, ratio, nil), 1e-9)
+	assert.Equal(t, 0.0, hedgingEfficiency(asset, hedge, 0, nil))
+	assert.Less(t, hedgingEfficiency(asset, hedge, ratio*1.5, nil), correlation*correlation)
+}
+
+func TestLogPrices(t *testing.T) {
+	assert.InDeltaSlice(t, []float64{0, 1}, logPrices([]float64{1, math.E}), 1e-12)
+}
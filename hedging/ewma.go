@@ -0,0 +1,63 @@
+package hedging
+
+import (
+	"fmt"
+	"math"
+)
+
+// ////////////////////////////////////////////////////////
+// Check parameters of the exponential weighting
+// ////////////////////////////////////////////////////////
+func validateWeighting(command Command) error {
+	if command.Lambda != 0 && command.HalfLife != 0 {
+		return fmt.Errorf("either lambda or half-life may be specified, not both")
+	}
+	if command.Lambda < 0 || command.Lambda >= 1 {
+		return fmt.Errorf("lambda must be in range (0, 1)")
+	}
+	if command.HalfLife < 0 {
+		return fmt.Errorf("half-life must be positive")
+	}
+	return nil
+}
+
+// ////////////////////////////////////////////////////////
+// Get decay factor of the exponential weighting, zero if
+// all observations are weighted equally
+// ////////////////////////////////////////////////////////
+func decayFactor(command Command) float64 {
+	if command.HalfLife > 0 {
+		return math.Pow(0.5, 1/command.HalfLife)
+	}
+	return command.Lambda
+}
+
+// ////////////////////////////////////////////////////////
+// Get weights of n observations ordered from the oldest to
+// the latest one: the latest observation has weight 1, the
+// previous one lambda, then lambda^2 and so on. Nil weights
+// mean equal weighting
+// ////////////////////////////////////////////////////////
+func ewmaWeights(n int, lambda float64) []float64 {
+	if lambda == 0 {
+		return nil
+	}
+
+	weights := make([]float64, n)
+	weight := 1.0
+	for idx := n - 1; idx >= 0; idx-- {
+		weights[idx] = weight
+		weight *= lambda
+	}
+	return weights
+}
+
+// ////////////////////////////////////////////////////////
+// Describe the weighting of observations for the output
+// ////////////////////////////////////////////////////////
+func describeWeighting(lambda float64) string {
+	if lambda == 0 {
+		return "equal weights"
+	}
+	return fmt.Sprintf("EWMA weights with lambda %f (half-life %.1f days)", lambda, math.Log(0.5)/math.Log(lambda))
+}
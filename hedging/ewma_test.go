@@ -0,0 +1,52 @@
+package hedging
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEWMAWeights(t *testing.T) {
+	assert.Nil(t, ewmaWeights(3, 0))
+
+	weights := ewmaWeights(3, 0.5)
+	assert.Equal(t, []float64{0.25, 0.5, 1}, weights)
+}
+
+func TestDecayFactor(t *testing.T) {
+	assert.Equal(t, 0.0, decayFactor(Command{}))
+	assert.Equal(t, 0.94, decayFactor(Command{Lambda: 0.94}))
+	assert.InDelta(t, 0.5, math.Pow(decayFactor(Command{HalfLife: 10}), 10), 1e-12)
+}
+
+func TestValidateWeighting(t *testing.T) {
+	assert.NoError(t, validateWeighting(Command{}))
+	assert.NoError(t, validateWeighting(Command{Lambda: 0.94}))
+	assert.NoError(t, validateWeighting(Command{HalfLife: 30}))
+	assert.Error(t, validateWeighting(Command{Lambda: 0.94, HalfLife: 30}))
+	assert.Error(t, validateWeighting(Command{Lambda: 1}))
+	assert.Error(t, validateWeighting(Command{HalfLife: -1}))
+}
+
+func TestWeightedRegressionMatchesOLS(t *testing.T) {
+	x := []float64{1, 2, 3, 4, 5}
+	y := []float64{3.1, 4.9, 7.2, 8.8, 11.0}
+
+	ols := linearRegression(x, y)
+	weighted := weightedRegression(x, y, []float64{2, 2, 2, 2, 2})
+	assert.InDelta(t, ols.Beta, weighted.Beta, 1e-9)
+	assert.InDelta(t, ols.BetaStdErr, weighted.BetaStdErr, 1e-9)
+	assert.InDelta(t, ols.AlphaPValue, weighted.AlphaPValue, 1e-9)
+}
+
+func TestWeightedRegressionFollowsRecentObservations(t *testing.T) {
+	// Beta is 1 on the older half of observations and 3 on the recent one
+	x := []float64{0.01, -0.02, 0.015, -0.01, 0.02, -0.01, 0.01, -0.015}
+	y := []float64{0.01, -0.02, 0.015, -0.01, 0.06, -0.03, 0.03, -0.045}
+
+	ols := linearRegression(x, y)
+	ewma := weightedRegression(x, y, ewmaWeights(len(x), 0.5))
+	assert.Greater(t, ewma.Beta, ols.Beta)
+	assert.Less(t, math.Abs(ewma.Beta-3), math.Abs(ols.Beta-3))
+}
@@ -1,24 +1,98 @@
 package hedging
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 type Command struct {
 	Asset        string
 	Hedge        string
 	HistoryDepth int
 	Report       string
+	Args         []string
+	Refresh      bool          // revalidate cached asset metadata
+	MetadataTTL  time.Duration // how long cached asset metadata is fresh
+
+	RollingWindow int    // observations in the window of rolling beta, 0 to disable
+	RollingStep   int    // observations between windows of rolling beta
+	Output        string // CSV file to export time series to
+
+	Lambda   float64 // decay factor of EWMA weights, 0 for equal weights
+	HalfLife float64 // half-life of EWMA weights in trading days, alternative to lambda
+
+	Lags      int    // leads and lags of index returns for Dimson and Scholes-Williams betas, 0 to disable
+	Threshold string // index return splitting downside and upside regimes, number or "mean"
+
+	Returns   string // simple or log returns
+	Prices    string // close, open or settle prices the returns are calculated on
+	Frequency string // daily, weekly or monthly sampling of returns
+
+	Robust bool // estimate beta and hedge ratio by robust estimators as well
+
+	Outliers      string  // none, zscore or mad detection of outliers in returns
+	OutlierLimit  float64 // detection limit in standard deviations, 0 for the default of the method
+	OutlierAction string  // winsorize or remove outliers
+
+	PositionSize  float64 // size of the hedged position, 0 to skip sizing the hedge in contracts
+	PositionUnits string  // shares or rub, units of the position size
+	Confidence    string  // comma separated confidence levels of variation margin estimates
+
+	Portfolio  string  // CSV or YAML file with positions of the portfolio
+	TargetBeta float64 // beta of the portfolio to reach by the hedge
+
+	ErrorCorrection bool // estimate hedge ratio by the error correction model as well
+	DCC             bool // estimate dynamic hedge ratio by DCC-GARCH(1,1)
+	JohansenLags    int  // lagged differences of the VECM of Johansen test
+
+	BacktestWindow int // trailing observations the backtest estimates the hedge ratio on
+	Rebalance      int // observations between re-estimations of the backtest hedge ratio
+
+	Kalman           bool    // estimate random walk beta by Kalman filter
+	ProcessNoise     float64 // variance of the daily change of beta, 0 estimates it
+	ObservationNoise float64 // variance of the residual return, 0 estimates it
+}
+
+// Option of the command and whether it was given
+type commandOption struct {
+	flag string
+	set  bool
 }
 
 type Executor interface {
 	Execute(command Command) error
 }
 
-func CreateCommand(commandName string) (Executor, error) {
+func CreateCommand(commandName string, cacheDSN string) (Executor, error) {
 	if commandName == "beta" {
-		return newBetaCalculator()
+		return newBetaCalculator(cacheDSN)
 	}
 	if commandName == "hedge" {
-		return newHedgeCalculator()
+		return newHedgeCalculator(cacheDSN)
+	}
+	if commandName == "coint" {
+		return newCointCalculator(cacheDSN)
+	}
+	if commandName == "backtest" {
+		return newBacktester(cacheDSN)
+	}
+	if commandName == "basis" {
+		return newBasisCalculator(cacheDSN)
+	}
+	if commandName == "cache" {
+		return newCacheMaintainer(cacheDSN)
 	}
 	return nil, fmt.Errorf("wrong command %s, run with -h for the help", commandName)
 }
+
+// ////////////////////////////////////////////////////////
+// Reject options which the mode of the command ignores
+// ////////////////////////////////////////////////////////
+func rejectOptions(mode string, options []commandOption) error {
+	for _, option := range options {
+		if option.set {
+			return fmt.Errorf("-%s is not supported for %s", option.flag, mode)
+		}
+	}
+	return nil
+}
@@ -1,23 +1,41 @@
 package hedging
 
 import (
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestCreateCommandWithValidCommand(t *testing.T) {
-	executor, err := CreateCommand("beta")
+	dir := t.TempDir()
+	executor, err := CreateCommand("beta", filepath.Join(dir, "beta.db"))
 	assert.NoError(t, err)
 	assert.NotNil(t, executor)
 
-	executor, err = CreateCommand("hedge")
+	executor, err = CreateCommand("hedge", filepath.Join(dir, "hedge.db"))
+	assert.NoError(t, err)
+	assert.NotNil(t, executor)
+
+	executor, err = CreateCommand("coint", filepath.Join(dir, "coint.db"))
+	assert.NoError(t, err)
+	assert.NotNil(t, executor)
+
+	executor, err = CreateCommand("backtest", filepath.Join(dir, "backtest.db"))
+	assert.NoError(t, err)
+	assert.NotNil(t, executor)
+
+	executor, err = CreateCommand("basis", filepath.Join(dir, "basis.db"))
+	assert.NoError(t, err)
+	assert.NotNil(t, executor)
+
+	executor, err = CreateCommand("cache", filepath.Join(dir, "cache.db"))
 	assert.NoError(t, err)
 	assert.NotNil(t, executor)
 }
 
 func TestCreateCommandWithInvalidCommand(t *testing.T) {
-	executor, err := CreateCommand("invalid")
+	executor, err := CreateCommand("invalid", filepath.Join(t.TempDir(), "cache.db"))
 	assert.Error(t, err)
 	assert.Nil(t, executor)
 	assert.EqualError(t, err, "wrong command invalid, run with -h for the help")
@@ -2,6 +2,7 @@ package hedging
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/TuliMyrskyTaivas/hedging/moex"
@@ -9,12 +10,11 @@ import (
 )
 
 type hedgeCalculator struct {
-	cache *Cache
+	cache Cache
 }
 
-func newHedgeCalculator() (Executor, error) {
-	const cacheFile = "cache.db"
-	cache, err := NewCache(cacheFile)
+func newHedgeCalculator(cacheDSN string) (Executor, error) {
+	cache, err := NewCache(cacheDSN)
 	if err != nil {
 		return nil, err
 	}
@@ -27,75 +27,196 @@ func (calculator *hedgeCalculator) Execute(command Command) error {
 	if len(command.Hedge) == 0 {
 		return fmt.Errorf("hedge asset was not specified. Run with -h for the help")
 	}
-
-	hedge, err := moex.GetAsset(command.Hedge)
+	if err := validateWeighting(command); err != nil {
+		return err
+	}
+	spec, err := newReturnSpec(command)
 	if err != nil {
 		return err
 	}
-
-	// Use future's underlying asset if base asset is not explicitly defined
-	var asset moex.Asset
-	if len(command.Asset) == 0 {
-		asset, err = hedge.GetFutureUnderlyingAsset()
-		fmt.Printf("Underlying asset for %s is %s\n", hedge.Secid, asset.Secid)
-	} else {
-		asset, err = moex.GetAsset(command.Asset)
+	cleaning, err := newCleaningSpec(command)
+	if err != nil {
+		return err
+	}
+	if err = validateKalman(command); err != nil {
+		return err
+	}
+	if err = validatePosition(command); err != nil {
+		return err
+	}
+	levels, err := parseConfidenceLevels(command.Confidence)
+	if err != nil {
+		return err
+	}
+	if len(command.Portfolio) > 0 {
+		err = rejectOptions("portfolio hedge", pairHedgeOptions(command))
+	} else if strings.Contains(command.Hedge, ",") {
+		err = rejectOptions("cross hedge by several instruments", pairHedgeOptions(command))
 	}
-
 	if err != nil {
 		return err
 	}
 
-	historyTo := time.Now()
-	historyFrom := historyTo.AddDate(0, -command.HistoryDepth, 0)
-	hedgeHistoryBegin := moex.ParseTime(hedge.HistoryFrom)
-	assetHistoryBegin := moex.ParseTime(asset.HistoryFrom)
-
-	// Adjust range on availability of data on MOEX
-	if hedgeHistoryBegin.After(historyFrom) {
-		historyFrom = hedgeHistoryBegin
+	resolver := newAssetResolver(calculator.cache, command)
+	if len(command.Portfolio) > 0 {
+		return calculator.executePortfolio(command, resolver, spec, cleaning, levels)
 	}
-	if assetHistoryBegin.After(historyFrom) {
-		historyFrom = assetHistoryBegin
+	if hedgeNames := strings.Split(command.Hedge, ","); len(hedgeNames) > 1 {
+		return calculator.executeCrossHedge(command, resolver, hedgeNames, spec, cleaning, levels)
 	}
 
-	hedgeHistory, err := hedge.GetHistory(historyFrom, historyTo)
+	pair, err := fetchHedgePair(resolver, command)
 	if err != nil {
 		return err
 	}
+	asset, assetHistory, hedge, hedgeHistory := pair.asset, pair.assetHistory, pair.hedge, pair.hedgeHistory
 
-	assetHistory, err := asset.GetHistory(historyFrom, historyTo)
-	if err != nil {
-		return err
+	fmt.Printf("Hedge is estimated on %s taken with %s\n", spec, describeWeighting(decayFactor(command)))
+	dates, assetChanges, hedgeChanges := pairedReturns(asset, assetHistory, hedge, hedgeHistory, spec)
+	dates, assetChanges, hedgeChanges, cleaned := cleanReturns(cleaning, dates, asset.Secid, assetChanges, hedge.Secid, hedgeChanges)
+	for _, summary := range cleaned {
+		fmt.Println(describeCleaning(cleaning, summary))
 	}
+	weights := ewmaWeights(len(hedgeChanges), decayFactor(command))
 
-	hedgeChanges := extractPriceChanges(hedgeHistory)
-	hedgeStdDev := stat.StdDev(hedgeChanges, nil)
+	hedgeStdDev := stat.StdDev(hedgeChanges, weights)
 	fmt.Printf("%s standard deviation: %f\n", hedge.Secid, hedgeStdDev)
 
-	assetChanges := extractPriceChanges(assetHistory)
-	assetStdDev := stat.StdDev(assetChanges, nil)
+	assetStdDev := stat.StdDev(assetChanges, weights)
 	fmt.Printf("%s standard deviation: %f\n", asset.Secid, assetStdDev)
 
-	correlation := stat.Correlation(hedgeChanges, assetChanges, nil)
-	fmt.Printf("Correlation between price changes of %s and %s: %f\n", hedge.Secid, asset.Secid, correlation)
+	correlation := stat.Correlation(hedgeChanges, assetChanges, weights)
+	fmt.Printf("Correlation between returns of %s and %s: %f\n", hedge.Secid, asset.Secid, correlation)
 
+	// Ratio of the hedge position value to the asset position value
 	optimalHedge := (assetStdDev / hedgeStdDev) * correlation
 	hedgingEfficiency := correlation * correlation
 	fmt.Printf("Optimal hedging coefficient is %f, hedging efficiency is %f\n", optimalHedge, hedgingEfficiency)
 
+	printer, err := GetPrinter()
+	if err != nil {
+		return err
+	}
+
+	if command.PositionSize > 0 {
+		info, err := resolver.GetFutureInfo(hedge.Secid)
+		if err != nil {
+			return fmt.Errorf("failed to get specification of %s, hedge must be a future to size it in contracts: %s", hedge.Secid, err)
+		}
+		price := futurePrice(hedgeHistory, info)
+		contract, err := contractValue(price, info)
+		if err != nil {
+			return err
+		}
+		position := positionValue(command, lastPrice(assetHistory))
+		contracts := sizeHedge(optimalHedge, position, info, contract)
+		printContractHedge(printer, hedge.Secid, contracts)
+		daily := marginReturns(hedge.Secid, hedgeHistory)
+		margin, err := estimateMargin(info, contracts.contracts, price, daily, ewmaWeights(len(daily), decayFactor(command)), levels)
+		if err != nil {
+			return err
+		}
+		printMarginEstimate(printer, hedge.Secid, margin)
+	}
+
+	if command.ErrorCorrection {
+		model, err := estimateErrorCorrection(pair, spec)
+		if err != nil {
+			return err
+		}
+		printer.Printf("Hedge ratios of %s on %s:\n", asset.Secid, hedge.Secid)
+		printHedgeComparison(printer, optimalHedge, model, assetChanges, hedgeChanges, weights)
+	}
+
+	if command.DCC {
+		model, err := dccHedge(assetChanges, hedgeChanges)
+		if err != nil {
+			return fmt.Errorf("failed to estimate dynamic hedge ratio: %s", err)
+		}
+		printDynamicHedge(printer, asset.Secid, hedge.Secid, dates, model, optimalHedge)
+		if len(command.Output) > 0 {
+			if err = exportDynamicHedge(command.Output, dates, model); err != nil {
+				return fmt.Errorf("failed to export dynamic hedge ratio: %s", err)
+			}
+			fmt.Printf("Dynamic hedge ratio exported to %s\n", command.Output)
+		}
+	}
+
+	if command.Kalman {
+		kalman, err := kalmanRegression(dates, hedgeChanges, assetChanges, command.ProcessNoise, command.ObservationNoise)
+		if err != nil {
+			return fmt.Errorf("failed to filter hedge ratio: %s", err)
+		}
+		printKalmanBeta(printer, "hedge ratio", asset.Secid, hedge.Secid, kalman)
+	}
+
+	if command.Robust {
+		printer.Printf("Hedge ratios of %s returns on %s returns by regression estimators:\n", asset.Secid, hedge.Secid)
+		printRobustFits(printer, "Hedge ratio", robustRegressions(hedgeChanges, assetChanges))
+	}
+
 	return nil
 }
 
-// ///////////////////////////////////////////////////////////////////
-// Extract array of price changes from MoexHistory
-// ///////////////////////////////////////////////////////////////////
-func extractPriceChanges(history []moex.HistoryItem) []float64 {
-	var changes []float64
-	var prevClose float64 = 0
-	for _, item := range history {
-		changes = append(changes, item.Close-prevClose)
-		prevClose = item.Close
-	}
-	return changes
+// Models estimated for the pair of the asset and the hedge only
+func pairHedgeOptions(command Command) []commandOption {
+	return []commandOption{
+		{flag: "ecm", set: command.ErrorCorrection},
+		{flag: "dcc", set: command.DCC},
+		{flag: "kalman", set: command.Kalman},
+		{flag: "robust", set: command.Robust},
+	}
+}
+
+// Asset and hedge instrument with their histories
+type hedgePair struct {
+	asset        moex.Asset
+	assetHistory []moex.HistoryItem
+	hedge        moex.Asset
+	hedgeHistory []moex.HistoryItem
+}
+
+// ////////////////////////////////////////////////////////
+// Get the hedge instrument and the asset of the command
+// with their histories. The asset defaults to the
+// underlying asset of the future
+// ////////////////////////////////////////////////////////
+func fetchHedgePair(resolver *assetResolver, command Command) (hedgePair, error) {
+	var pair hedgePair
+	hedge, err := resolver.GetAsset(command.Hedge)
+	if err != nil {
+		return pair, err
+	}
+
+	var asset moex.Asset
+	if len(command.Asset) == 0 {
+		asset, err = resolver.GetFutureUnderlyingAsset(hedge)
+		fmt.Printf("Underlying asset for %s is %s\n", hedge.Secid, asset.Secid)
+	} else {
+		asset, err = resolver.GetAsset(command.Asset)
+	}
+	if err != nil {
+		return pair, err
+	}
+
+	historyFrom, historyTo := historyRange(command.HistoryDepth, asset, hedge)
+	histories, err := fetchHistories([]moex.Asset{asset, hedge}, historyFrom, historyTo)
+	if err != nil {
+		return pair, err
+	}
+	return hedgePair{asset: asset, assetHistory: histories[0], hedge: hedge, hedgeHistory: histories[1]}, nil
+}
+
+// ////////////////////////////////////////////////////////
+// Get range of history available on MOEX for all assets
+// ////////////////////////////////////////////////////////
+func historyRange(depthMonth int, assets ...moex.Asset) (time.Time, time.Time) {
+	historyTo := time.Now()
+	historyFrom := historyTo.AddDate(0, -depthMonth, 0)
+	for _, asset := range assets {
+		if begin := moex.ParseTime(asset.HistoryFrom); begin.After(historyFrom) {
+			historyFrom = begin
+		}
+	}
+	return historyFrom, historyTo
 }
@@ -1,14 +1,14 @@
 package hedging
 
 import (
+	"path/filepath"
 	"testing"
 
-	"github.com/TuliMyrskyTaivas/hedging/moex"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestNewHedgeCalculator(t *testing.T) {
-	calculator, err := newHedgeCalculator()
+	calculator, err := newHedgeCalculator(filepath.Join(t.TempDir(), "cache.db"))
 	assert.NoError(t, err)
 	assert.NotNil(t, calculator)
 }
@@ -38,17 +38,6 @@ func TestExecuteWithInvalidHedge(t *testing.T) {
 	assert.Contains(t, err.Error(), "asset INVALID_HEDGE not found on MOEX")
 }
 
-func TestExtractPriceChanges(t *testing.T) {
-	history := []moex.HistoryItem{
-		{Close: 100},
-		{Close: 105},
-		{Close: 110},
-	}
-	expectedChanges := []float64{100, 5, 5}
-	changes := extractPriceChanges(history)
-	assert.Equal(t, expectedChanges, changes)
-}
-
 func TestExecuteWithValidData(t *testing.T) {
 	calculator := &hedgeCalculator{}
 	command := Command{
@@ -60,3 +49,16 @@ func TestExecuteWithValidData(t *testing.T) {
 	err := calculator.Execute(command)
 	assert.NoError(t, err)
 }
+
+func TestCrossHedgeWithoutAsset(t *testing.T) {
+	calculator := &hedgeCalculator{}
+	err := calculator.Execute(Command{Hedge: "BRF5,SiZ4", HistoryDepth: 6})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "asset must be specified")
+}
+
+func TestCrossHedgeRejectsPairModels(t *testing.T) {
+	calculator := &hedgeCalculator{}
+	err := calculator.Execute(Command{Asset: "SBER", Hedge: "BRF5,SiZ4", HistoryDepth: 6, DCC: true})
+	assert.EqualError(t, err, "-dcc is not supported for cross hedge by several instruments")
+}
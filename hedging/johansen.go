@@ -0,0 +1,180 @@
+package hedging
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"golang.org/x/text/message"
+	"gonum.org/v1/gonum/mat"
+)
+
+// Critical values of Johansen test for two variables with constant,
+// MacKinnon, Haug and Michelis (1999), by rank under the null hypothesis
+// and criticalLevels
+var (
+	johansenTraceCritical = [2][3]float64{{19.9349, 15.4943, 13.4294}, {6.6349, 3.8415, 2.7055}}
+	johansenMaxCritical   = [2][3]float64{{18.5200, 14.2639, 12.2971}, {6.6349, 3.8415, 2.7055}}
+)
+
+// Johansen test of the cointegration rank
+type johansen struct {
+	eigenvalues []float64 // in descending order
+	trace       []float64 // trace statistics of rank <= r
+	maxEigen    []float64 // maximum eigenvalue statistics of rank r against r+1
+	ratio       float64   // units of the second series per unit of the first one in the strongest relationship
+	lags        int
+}
+
+// ////////////////////////////////////////////////////////
+// Johansen test of a pair of price series on VECM with the
+// specified number of lagged differences and constant
+// ////////////////////////////////////////////////////////
+func johansenTest(series [][]float64, lags int) (johansen, error) {
+	result := johansen{lags: lags}
+	if len(series) != 2 {
+		return result, fmt.Errorf("critical values of Johansen test are known for pairs only")
+	}
+
+	dimension := len(series)
+	rows := len(series[0]) - 1 - lags
+	if rows <= dimension*(lags+1)+1 {
+		return result, fmt.Errorf("%d observations are not enough for Johansen test with %d lags", len(series[0]), lags)
+	}
+
+	// Differences, levels and lagged differences with constant for t = lags+1..
+	z0 := mat.NewDense(rows, dimension, nil)
+	z1 := mat.NewDense(rows, dimension, nil)
+	z2 := mat.NewDense(rows, dimension*lags+1, nil)
+	for row := 0; row < rows; row++ {
+		t := row + lags + 1
+		for col, values := range series {
+			z0.Set(row, col, values[t]-values[t-1])
+			z1.Set(row, col, values[t-1])
+			for lag := 1; lag <= lags; lag++ {
+				z2.Set(row, (lag-1)*dimension+col, values[t-lag]-values[t-lag-1])
+			}
+		}
+		z2.Set(row, dimension*lags, 1)
+	}
+
+	r0, err := partialOut(z0, z2)
+	if err != nil {
+		return result, err
+	}
+	r1, err := partialOut(z1, z2)
+	if err != nil {
+		return result, err
+	}
+
+	var s00, s11, s01 mat.Dense
+	s00.Mul(r0.T(), r0)
+	s00.Scale(1/float64(rows), &s00)
+	s11.Mul(r1.T(), r1)
+	s11.Scale(1/float64(rows), &s11)
+	s01.Mul(r0.T(), r1)
+	s01.Scale(1/float64(rows), &s01)
+
+	// Solve |λ S11 - S10 S00^-1 S01| = 0 as symmetric problem via Cholesky of S11
+	var cholesky mat.Cholesky
+	if ok := cholesky.Factorize(symmetric(&s11)); !ok {
+		return result, fmt.Errorf("price levels are collinear")
+	}
+	var lower mat.TriDense
+	cholesky.LTo(&lower)
+	var lowerInverse mat.Dense
+	if err = lowerInverse.Inverse(&lower); err != nil {
+		return result, fmt.Errorf("price levels are collinear: %s", err)
+	}
+	var s00Inverse mat.Dense
+	if err = s00Inverse.Inverse(&s00); err != nil {
+		return result, fmt.Errorf("price changes are collinear: %s", err)
+	}
+
+	var product mat.Dense
+	product.Product(&lowerInverse, s01.T(), &s00Inverse, &s01, lowerInverse.T())
+	var eigen mat.EigenSym
+	if ok := eigen.Factorize(symmetric(&product), true); !ok {
+		return result, fmt.Errorf("failed to find eigenvalues of Johansen test")
+	}
+	values := eigen.Values(nil)
+	var vectors mat.Dense
+	eigen.VectorsTo(&vectors)
+
+	order := []int{0, 1}
+	sort.Slice(order, func(i, j int) bool { return values[order[i]] > values[order[j]] })
+	for _, idx := range order {
+		result.eigenvalues = append(result.eigenvalues, values[idx])
+	}
+	for rank := range result.eigenvalues {
+		var trace float64
+		for _, value := range result.eigenvalues[rank:] {
+			trace -= float64(rows) * math.Log(1-value)
+		}
+		result.trace = append(result.trace, trace)
+		result.maxEigen = append(result.maxEigen, -float64(rows)*math.Log(1-result.eigenvalues[rank]))
+	}
+
+	// Cointegrating vector of the largest eigenvalue: beta = L^-T v
+	var beta mat.VecDense
+	beta.MulVec(lowerInverse.T(), vectors.ColView(order[0]))
+	result.ratio = -beta.AtVec(1) / beta.AtVec(0)
+	return result, nil
+}
+
+// ////////////////////////////////////////////////////////
+// Residuals of regression of each column on the regressors
+// ////////////////////////////////////////////////////////
+func partialOut(target *mat.Dense, regressors *mat.Dense) (*mat.Dense, error) {
+	var coefficients mat.Dense
+	if err := coefficients.Solve(regressors, target); err != nil {
+		return nil, fmt.Errorf("lagged price changes are collinear: %s", err)
+	}
+	var residuals mat.Dense
+	residuals.Mul(regressors, &coefficients)
+	residuals.Sub(target, &residuals)
+	return &residuals, nil
+}
+
+// ////////////////////////////////////////////////////////
+// Symmetric matrix from the square one, averaging rounding
+// differences of the elements mirrored by the diagonal
+// ////////////////////////////////////////////////////////
+func symmetric(matrix *mat.Dense) *mat.SymDense {
+	size, _ := matrix.Dims()
+	result := mat.NewSymDense(size, nil)
+	for i := 0; i < size; i++ {
+		for j := i; j < size; j++ {
+			result.SetSym(i, j, (matrix.At(i, j)+matrix.At(j, i))/2)
+		}
+	}
+	return result
+}
+
+// ////////////////////////////////////////////////////////
+// Cointegration rank: the first rank which is not rejected
+// by the trace test at the significance level
+// ////////////////////////////////////////////////////////
+func (test johansen) rank(level int) int {
+	for rank, trace := range test.trace {
+		if trace < johansenTraceCritical[rank][level] {
+			return rank
+		}
+	}
+	return len(test.trace)
+}
+
+// ////////////////////////////////////////////////////////
+// Print results of Johansen test
+// ////////////////////////////////////////////////////////
+func printJohansen(printer *message.Printer, test johansen) {
+	printer.Printf("Johansen test (%d lags):\n", test.lags)
+	for rank := range test.eigenvalues {
+		printer.Printf("\tr <= %d: eigenvalue %f, trace %f, max-eigen %f\n", rank, test.eigenvalues[rank], test.trace[rank], test.maxEigen[rank])
+		for idx, level := range criticalLevels {
+			printer.Printf("\t\tcritical values at %-3s trace %f, max-eigen %f\n", level,
+				johansenTraceCritical[rank][idx], johansenMaxCritical[rank][idx])
+		}
+	}
+	printer.Printf("\tcointegration rank at 5%% is %d, cointegrating hedge ratio %f\n", test.rank(1), test.ratio)
+}
@@ -0,0 +1,150 @@
+package hedging
+
+import (
+	"fmt"
+	"math"
+
+	"golang.org/x/text/message"
+	"gonum.org/v1/gonum/optimize"
+)
+
+// Variance of the initial beta, large enough to let the first observations define it
+const diffuseVariance = 100.0
+
+// Beta filtered by the Kalman filter on the date
+type KalmanBeta struct {
+	Date   string
+	Beta   float64
+	StdErr float64 // standard deviation of the filtered beta
+}
+
+// Random walk beta estimated by the Kalman filter
+type kalmanFit struct {
+	alpha            float64 // constant intercept of the observation equation
+	processNoise     float64 // variance of the daily change of beta
+	observationNoise float64 // variance of the residual return
+	estimated        bool    // noise variances are estimated by maximum likelihood
+	path             []KalmanBeta
+}
+
+// ////////////////////////////////////////////////////////
+// Check noise variances of the Kalman filter
+// ////////////////////////////////////////////////////////
+func validateKalman(command Command) error {
+	if command.ProcessNoise < 0 || command.ObservationNoise < 0 {
+		return fmt.Errorf("noise variances of Kalman filter must not be negative")
+	}
+	return nil
+}
+
+// ////////////////////////////////////////////////////////
+// Estimate beta following a random walk:
+// y[t] = α + β[t] x[t] + ε[t], ε ~ N(0, R)
+// β[t] = β[t-1] + η[t],        η ~ N(0, Q)
+// Intercept α is taken from the ordinary regression. Zero
+// noise variances are estimated by maximum likelihood
+// ////////////////////////////////////////////////////////
+func kalmanRegression(dates []string, x []float64, y []float64, processNoise float64, observationNoise float64) (kalmanFit, error) {
+	result := kalmanFit{processNoise: processNoise, observationNoise: observationNoise}
+	if len(x) < 3 {
+		return result, fmt.Errorf("at least 3 observations are required, got %d", len(x))
+	}
+	ols := linearRegression(x, y)
+	result.alpha = ols.Alpha
+
+	if processNoise == 0 || observationNoise == 0 {
+		result.estimated = true
+		initial := []float64{math.Log(ols.ResidualVolatility * ols.ResidualVolatility * 1e-3), math.Log(ols.ResidualVolatility * ols.ResidualVolatility)}
+		if processNoise > 0 {
+			initial[0] = math.Log(processNoise)
+		}
+		if observationNoise > 0 {
+			initial[1] = math.Log(observationNoise)
+		}
+		// Variances given by the command are kept fixed
+		unpack := func(parameters []float64) (float64, float64) {
+			q, r := math.Exp(parameters[0]), math.Exp(parameters[1])
+			if processNoise > 0 {
+				q = processNoise
+			}
+			if observationNoise > 0 {
+				r = observationNoise
+			}
+			return q, r
+		}
+
+		problem := optimize.Problem{
+			Func: func(parameters []float64) float64 {
+				q, r := unpack(parameters)
+				value, _ := kalmanFilter(x, y, result.alpha, q, r)
+				if math.IsNaN(value) {
+					return math.Inf(1)
+				}
+				return value
+			},
+		}
+		optimum, err := optimize.Minimize(problem, initial, nil, &optimize.NelderMead{})
+		if err != nil {
+			return result, fmt.Errorf("likelihood maximization failed: %s", err)
+		}
+		result.processNoise, result.observationNoise = unpack(optimum.X)
+	}
+
+	_, states := kalmanFilter(x, y, result.alpha, result.processNoise, result.observationNoise)
+	result.path = make([]KalmanBeta, len(states))
+	for idx, state := range states {
+		result.path[idx] = KalmanBeta{Date: dates[idx], Beta: state[0], StdErr: math.Sqrt(state[1])}
+	}
+	return result, nil
+}
+
+// ////////////////////////////////////////////////////////
+// Run the filter from the diffuse initial beta, return
+// negative log-likelihood of the prediction errors and the
+// filtered beta with its variance for every observation.
+// The first observation only initializes the filter and is
+// not included in the likelihood
+// ////////////////////////////////////////////////////////
+func kalmanFilter(x []float64, y []float64, alpha float64, processNoise float64, observationNoise float64) (float64, [][2]float64) {
+	states := make([][2]float64, len(x))
+	beta, variance := 0.0, diffuseVariance
+	likelihood := 0.0
+	for idx := range x {
+		if idx > 0 {
+			variance += processNoise
+		}
+		predictionVariance := x[idx]*x[idx]*variance + observationNoise
+		innovation := y[idx] - alpha - x[idx]*beta
+		gain := variance * x[idx] / predictionVariance
+		beta += gain * innovation
+		variance *= 1 - gain*x[idx]
+		if idx > 0 {
+			likelihood += math.Log(predictionVariance) + innovation*innovation/predictionVariance
+		}
+		states[idx] = [2]float64{beta, variance}
+	}
+	return likelihood / 2, states
+}
+
+// Filtered beta on the last observation
+func (fit kalmanFit) current() KalmanBeta {
+	return fit.path[len(fit.path)-1]
+}
+
+// ////////////////////////////////////////////////////////
+// Print parameters of the filter and the filtered path
+// ////////////////////////////////////////////////////////
+func printKalmanBeta(printer *message.Printer, label string, asset string, index string, fit kalmanFit) {
+	source := "given"
+	if fit.estimated {
+		source = "estimated by maximum likelihood"
+	}
+	printer.Printf("Kalman filter %s of %s on %s:\n", label, asset, index)
+	printer.Printf("\tprocess noise %g, observation noise %g (%s), alpha %f\n", fit.processNoise, fit.observationNoise, source, fit.alpha)
+	current := fit.current()
+	printer.Printf("\tCurrent %s %f (standard error %f) on %s\n", label, current.Beta, current.StdErr, current.Date)
+	printer.Printf("\t%-10s %10s %10s\n", "Date", "Beta", "Std error")
+	for _, point := range fit.path {
+		printer.Printf("\t%-10s %10f %10f\n", point.Date, point.Beta, point.StdErr)
+	}
+}
@@ -0,0 +1,81 @@
+package hedging
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gonum.org/v1/gonum/stat"
+)
+
+// Returns with beta following a random walk and the true beta path
+func randomWalkBeta(seed int64, n int, processNoise float64, observationNoise float64) ([]string, []float64, []float64, []float64) {
+	random := rand.New(rand.NewSource(seed))
+	dates := make([]string, n)
+	x := make([]float64, n)
+	y := make([]float64, n)
+	betas := make([]float64, n)
+	beta := 1.0
+	for idx := 0; idx < n; idx++ {
+		beta += math.Sqrt(processNoise) * random.NormFloat64()
+		dates[idx] = fmt.Sprintf("day %d", idx)
+		x[idx] = 0.01 * random.NormFloat64()
+		y[idx] = beta*x[idx] + math.Sqrt(observationNoise)*random.NormFloat64()
+		betas[idx] = beta
+	}
+	return dates, x, y, betas
+}
+
+func TestKalmanTracksRandomWalkBeta(t *testing.T) {
+	dates, x, y, betas := randomWalkBeta(1, 2000, 1e-3, 1e-5)
+	fit, err := kalmanRegression(dates, x, y, 0, 0)
+	assert.Nil(t, err)
+	assert.True(t, fit.estimated)
+	assert.Len(t, fit.path, len(x))
+
+	// Noise variances are recovered within the order of magnitude
+	assert.InDelta(t, math.Log10(1e-3), math.Log10(fit.processNoise), 0.5)
+	assert.InDelta(t, math.Log10(1e-5), math.Log10(fit.observationNoise), 0.2)
+
+	filtered := make([]float64, len(fit.path))
+	for idx, point := range fit.path {
+		filtered[idx] = point.Beta
+	}
+	assert.Greater(t, stat.Correlation(filtered[100:], betas[100:], nil), 0.9)
+	assert.InDelta(t, betas[len(betas)-1], fit.current().Beta, 4*fit.current().StdErr)
+	assert.Equal(t, "day 1999", fit.current().Date)
+}
+
+func TestKalmanConstantBeta(t *testing.T) {
+	dates, x, y, _ := randomWalkBeta(2, 1000, 0, 1e-5)
+	fit, err := kalmanRegression(dates, x, y, 1e-12, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 1e-12, fit.processNoise)
+
+	// Without process noise the filter converges to the ordinary regression
+	assert.InDelta(t, linearRegression(x, y).Beta, fit.current().Beta, 0.01)
+	assert.Less(t, fit.path[len(fit.path)-1].StdErr, fit.path[10].StdErr)
+}
+
+func TestKalmanGivenNoise(t *testing.T) {
+	dates, x, y, _ := randomWalkBeta(3, 200, 1e-3, 1e-5)
+	fit, err := kalmanRegression(dates, x, y, 2e-3, 3e-5)
+	assert.Nil(t, err)
+	assert.False(t, fit.estimated)
+	assert.Equal(t, 2e-3, fit.processNoise)
+	assert.Equal(t, 3e-5, fit.observationNoise)
+}
+
+func TestKalmanTooShort(t *testing.T) {
+	_, err := kalmanRegression([]string{"a", "b"}, []float64{1, 2}, []float64{1, 2}, 0, 0)
+	assert.NotNil(t, err)
+}
+
+func TestValidateKalman(t *testing.T) {
+	assert.Nil(t, validateKalman(Command{}))
+	assert.Nil(t, validateKalman(Command{ProcessNoise: 1e-4, ObservationNoise: 1e-4}))
+	assert.NotNil(t, validateKalman(Command{ProcessNoise: -1}))
+	assert.NotNil(t, validateKalman(Command{ObservationNoise: -1}))
+}
@@ -0,0 +1,126 @@
+package hedging
+
+import (
+	"fmt"
+	"math"
+
+	"golang.org/x/text/message"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// Significance level of the tests of lagged coefficients
+const significanceLevel = 0.05
+
+// Betas corrected for nonsynchronous trading of thin assets
+type LaggedBeta struct {
+	Lags            int
+	Dimson          float64
+	ScholesWilliams float64
+	// Slopes on the index returns shifted by -Lags..+Lags days,
+	// negative shift is the index return preceding the asset one
+	Coefficients []float64
+	PValues      []float64
+}
+
+// ////////////////////////////////////////////////////////
+// Coefficient on the index return shifted by lag days
+// ////////////////////////////////////////////////////////
+func (beta LaggedBeta) coefficient(lag int) float64 {
+	return beta.Coefficients[lag+beta.Lags]
+}
+
+// ////////////////////////////////////////////////////////
+// Lags with coefficients significantly different from zero,
+// which indicates that the asset is traded thinly
+// ////////////////////////////////////////////////////////
+func (beta LaggedBeta) significantLags() []int {
+	var lags []int
+	for lag := -beta.Lags; lag <= beta.Lags; lag++ {
+		if lag != 0 && beta.PValues[lag+beta.Lags] < significanceLevel {
+			lags = append(lags, lag)
+		}
+	}
+	return lags
+}
+
+// ////////////////////////////////////////////////////////
+// Estimate Dimson beta as the sum of slopes of multiple
+// regression on leading, synchronous and lagging index
+// returns, and Scholes-Williams beta as the sum of slopes
+// of separate regressions divided by 1 + 2 * sum of index
+// autocorrelations
+// ////////////////////////////////////////////////////////
+func laggedRegression(x []float64, y []float64, lags int) (LaggedBeta, error) {
+	result := LaggedBeta{Lags: lags}
+	regressors := 2*lags + 1
+	rows := len(x) - 2*lags
+	if rows <= regressors+1 {
+		return result, fmt.Errorf("%d observations are not enough to estimate beta with %d lags", len(x), lags)
+	}
+
+	// Dimson: y[t] = alpha + sum of beta[k] * x[t+k]
+	design := mat.NewDense(rows, regressors+1, nil)
+	target := mat.NewVecDense(rows, nil)
+	for row := 0; row < rows; row++ {
+		t := row + lags
+		design.Set(row, 0, 1)
+		for lag := -lags; lag <= lags; lag++ {
+			design.Set(row, lag+lags+1, x[t+lag])
+		}
+		target.SetVec(row, y[t])
+	}
+
+	var coefficients mat.VecDense
+	if err := coefficients.SolveVec(design, target); err != nil {
+		return result, fmt.Errorf("failed to solve lagged regression: %s", err)
+	}
+
+	var covariance mat.Dense
+	covariance.Mul(design.T(), design)
+	if err := covariance.Inverse(&covariance); err != nil {
+		return result, fmt.Errorf("index returns are collinear: %s", err)
+	}
+
+	var fitted, residuals mat.VecDense
+	fitted.MulVec(design, &coefficients)
+	residuals.SubVec(target, &fitted)
+	freedom := float64(rows - regressors - 1)
+	residualVariance := mat.Dot(&residuals, &residuals) / freedom
+	studentT := distuv.StudentsT{Mu: 0, Sigma: 1, Nu: freedom}
+
+	for idx := 1; idx <= regressors; idx++ {
+		coefficient := coefficients.AtVec(idx)
+		_, pValue := tTest(coefficient, math.Sqrt(residualVariance*covariance.At(idx, idx)), studentT)
+		result.Coefficients = append(result.Coefficients, coefficient)
+		result.PValues = append(result.PValues, pValue)
+		result.Dimson += coefficient
+	}
+
+	// Scholes-Williams: separate regressions on the same rows
+	var slopes, autocorrelations float64
+	for lag := -lags; lag <= lags; lag++ {
+		shifted := x[lags+lag : lags+lag+rows]
+		_, slope := stat.LinearRegression(shifted, y[lags:lags+rows], nil, false)
+		slopes += slope
+		if lag > 0 {
+			autocorrelations += stat.Correlation(x[:len(x)-lag], x[lag:], nil)
+		}
+	}
+	result.ScholesWilliams = slopes / (1 + 2*autocorrelations)
+	return result, nil
+}
+
+// ////////////////////////////////////////////////////////
+// Print betas corrected for nonsynchronous trading
+// ////////////////////////////////////////////////////////
+func printLaggedBeta(printer *message.Printer, beta LaggedBeta) {
+	printer.Printf("\tDimson beta %f, Scholes-Williams beta %f (%d lags)\n", beta.Dimson, beta.ScholesWilliams, beta.Lags)
+	for lag := -beta.Lags; lag <= beta.Lags; lag++ {
+		printer.Printf("\t\tindex return at t%+d: slope %f, p-value %f\n", lag, beta.coefficient(lag), beta.PValues[lag+beta.Lags])
+	}
+	if lags := beta.significantLags(); len(lags) > 0 {
+		printer.Printf("\tWARNING: lagged coefficients are significant at %v, the asset seems to be traded thinly\n", lags)
+	}
+}
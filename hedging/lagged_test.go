@@ -0,0 +1,40 @@
+package hedging
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLaggedRegressionOnThinAsset(t *testing.T) {
+	// Half of the index move is reflected in the asset price on the next day
+	random := rand.New(rand.NewSource(1))
+	x := make([]float64, 500)
+	y := make([]float64, 500)
+	for idx := range x {
+		x[idx] = random.NormFloat64() * 0.01
+		y[idx] = 0.5*x[idx] + random.NormFloat64()*0.002
+		if idx > 0 {
+			y[idx] += 0.5 * x[idx-1]
+		}
+	}
+
+	raw := linearRegression(x, y)
+	assert.InDelta(t, 0.5, raw.Beta, 0.05)
+
+	result, err := laggedRegression(x, y, 1)
+	assert.NoError(t, err)
+	assert.Len(t, result.Coefficients, 3)
+	assert.InDelta(t, 0.5, result.coefficient(-1), 0.05)
+	assert.InDelta(t, 0.5, result.coefficient(0), 0.05)
+	assert.InDelta(t, 0.0, result.coefficient(1), 0.05)
+	assert.InDelta(t, 1.0, result.Dimson, 0.05)
+	assert.InDelta(t, 1.0, result.ScholesWilliams, 0.1)
+	assert.Equal(t, []int{-1}, result.significantLags())
+}
+
+func TestLaggedRegressionNeedsObservations(t *testing.T) {
+	_, err := laggedRegression([]float64{1, 2, 3, 4, 5}, []float64{1, 2, 3, 4, 5}, 1)
+	assert.Error(t, err)
+}
@@ -0,0 +1,212 @@
+package hedging
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+type cacheMaintainer struct {
+	cacheDSN string
+}
+
+// ////////////////////////////////////////////////////////
+// Constructor
+// ////////////////////////////////////////////////////////
+func newCacheMaintainer(cacheDSN string) (Executor, error) {
+	return &cacheMaintainer{cacheDSN: cacheDSN}, nil
+}
+
+// ////////////////////////////////////////////////////////
+// Command executor
+// ////////////////////////////////////////////////////////
+func (maintainer *cacheMaintainer) Execute(command Command) error {
+	if len(command.Args) == 0 {
+		return fmt.Errorf("cache operation was not specified. Run with -h for the help")
+	}
+
+	if command.Args[0] == "migrate" {
+		return maintainer.migrate(command)
+	}
+
+	cache, err := NewCache(maintainer.cacheDSN)
+	if err != nil {
+		return err
+	}
+	defer cache.Close()
+
+	args := command.Args[1:]
+	switch command.Args[0] {
+	case "stats":
+		return printCacheStats(cache)
+	case "purge":
+		return purgeCache(cache, args)
+	case "vacuum":
+		return cache.Vacuum()
+	case "export":
+		return exportCache(cache, args)
+	case "import":
+		return importCache(cache, args)
+	}
+	return fmt.Errorf("wrong cache operation %s, run with -h for the help", command.Args[0])
+}
+
+// ////////////////////////////////////////////////////////
+// Upgrade schema of the cache and the report (if specified)
+// ////////////////////////////////////////////////////////
+func (maintainer *cacheMaintainer) migrate(command Command) error {
+	err := migrateStorage(maintainer.cacheDSN, cacheSchema)
+	if err != nil {
+		return err
+	}
+
+	if len(command.Report) > 0 {
+		return migrateStorage(command.Report, reportSchema)
+	}
+	return nil
+}
+
+func migrateStorage(dsn string, schema schemaKind) error {
+	backend, _ := parseDSN(dsn)
+	if backend == memoryBackend {
+		fmt.Printf("%s is kept in memory, nothing to migrate\n", dsn)
+		return nil
+	}
+
+	database, err := openSQLDatabase(dsn)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	name := redactDSN(dsn)
+	from, to, err := migrateSchema(database.db, database.dialect, name, database.dialect.migrations(schema))
+	if err != nil {
+		return err
+	}
+
+	if from == to {
+		fmt.Printf("%s is up to date (schema version %d)\n", name, to)
+	} else {
+		fmt.Printf("%s migrated from schema version %d to %d\n", name, from, to)
+	}
+	return nil
+}
+
+// ////////////////////////////////////////////////////////
+// Print number of records and date range for every ticker
+// ////////////////////////////////////////////////////////
+func printCacheStats(cache Cache) error {
+	tickers, err := cache.GetTickers()
+	if err != nil {
+		return err
+	}
+
+	const timeFormat = "2006-01-02"
+	total := 0
+	fmt.Printf("%-16s %8s %10s %10s\n", "Ticker", "Records", "From", "Till")
+	for _, ticker := range tickers {
+		count, err := cache.CountProfits(ticker)
+		if err != nil {
+			return err
+		}
+		from, till, err := cache.GetAvailableRange(ticker)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%-16s %8d %10s %10s\n", ticker, count, from.Format(timeFormat), till.Format(timeFormat))
+		total += count
+	}
+	fmt.Printf("Cache contains %d records for %d tickers\n", total, len(tickers))
+	return nil
+}
+
+// ////////////////////////////////////////////////////////
+// Remove records of the ticker or records older than
+// the specified date (YYYY-MM-DD) or number of days
+// ////////////////////////////////////////////////////////
+func purgeCache(cache Cache, args []string) error {
+	flags := flag.NewFlagSet("purge", flag.ContinueOnError)
+	olderThan := flags.String("older-than", "", "date (YYYY-MM-DD) or number of days")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var removed int64
+	var cutoff time.Time
+	var err error
+	if len(*olderThan) > 0 {
+		cutoff, err = parseCutoff(*olderThan)
+		if err != nil {
+			return err
+		}
+		removed, err = cache.PurgeOlderThan(cutoff)
+		if err != nil {
+			return err
+		}
+	} else if flags.NArg() == 1 {
+		removed, err = cache.PurgeTicker(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+	} else {
+		return fmt.Errorf("purge requires either a ticker or --older-than. Run with -h for the help")
+	}
+
+	fmt.Printf("%d records removed from cache\n", removed)
+	return nil
+}
+
+func parseCutoff(value string) (time.Time, error) {
+	days, err := strconv.Atoi(value)
+	if err == nil {
+		return time.Now().AddDate(0, 0, -days), nil
+	}
+
+	const timeFormat = "2006-01-02"
+	cutoff, err := time.Parse(timeFormat, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("wrong value for --older-than: %s", value)
+	}
+	return cutoff, nil
+}
+
+func exportCache(cache Cache, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("export requires the output file name. Run with -h for the help")
+	}
+
+	file, err := os.Create(args[0])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	count, err := ExportProfits(cache, file)
+	if err != nil {
+		return fmt.Errorf("failed to export cache: %s", err)
+	}
+	fmt.Printf("%d records exported to %s\n", count, args[0])
+	return nil
+}
+
+func importCache(cache Cache, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("import requires the input file name. Run with -h for the help")
+	}
+
+	file, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	count, err := ImportProfits(cache, file)
+	if err != nil {
+		return fmt.Errorf("failed to import cache: %s", err)
+	}
+	fmt.Printf("%d records imported from %s\n", count, args[0])
+	return nil
+}
@@ -0,0 +1,81 @@
+package hedging
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheMaintainerWithoutOperation(t *testing.T) {
+	maintainer := &cacheMaintainer{cacheDSN: filepath.Join(t.TempDir(), "cache.db")}
+	err := maintainer.Execute(Command{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "cache operation was not specified")
+
+	err = maintainer.Execute(Command{Args: []string{"invalid"}})
+	assert.EqualError(t, err, "wrong cache operation invalid, run with -h for the help")
+}
+
+func TestCacheMaintainerMigrate(t *testing.T) {
+	dir := t.TempDir()
+	maintainer := &cacheMaintainer{cacheDSN: filepath.Join(dir, "cache.db")}
+	command := Command{Report: filepath.Join(dir, "report.db"), Args: []string{"migrate"}}
+
+	err := maintainer.Execute(command)
+	assert.NoError(t, err)
+
+	cache, err := openSQLDatabase(maintainer.cacheDSN)
+	assert.NoError(t, err)
+	defer cache.Close()
+	version, err := getSchemaVersion(cache.db)
+	assert.NoError(t, err)
+	assert.Equal(t, latestSchemaVersion(cacheMigrations), version)
+
+	report, err := openSQLDatabase(command.Report)
+	assert.NoError(t, err)
+	defer report.Close()
+	version, err = getSchemaVersion(report.db)
+	assert.NoError(t, err)
+	assert.Equal(t, latestSchemaVersion(reportMigrations), version)
+}
+
+func TestCacheMaintainerExportImport(t *testing.T) {
+	dir := t.TempDir()
+	source := &cacheMaintainer{cacheDSN: filepath.Join(dir, "source.db")}
+	target := &cacheMaintainer{cacheDSN: filepath.Join(dir, "target.db")}
+	csvFile := filepath.Join(dir, "profits.csv")
+
+	cache, err := NewCache(source.cacheDSN)
+	assert.NoError(t, err)
+	assert.NoError(t, cache.AddProfits("SBER", []string{"2024-01-02", "2024-01-03"}, []float64{0.01, -0.02}))
+	cache.Close()
+
+	assert.NoError(t, source.Execute(Command{Args: []string{"export", csvFile}}))
+	assert.NoError(t, target.Execute(Command{Args: []string{"import", csvFile}}))
+	assert.NoError(t, target.Execute(Command{Args: []string{"stats"}}))
+	assert.NoError(t, target.Execute(Command{Args: []string{"purge", "--older-than", "2024-01-03"}}))
+	assert.NoError(t, target.Execute(Command{Args: []string{"vacuum"}}))
+	assert.Error(t, target.Execute(Command{Args: []string{"purge"}}))
+
+	cache, err = NewCache(target.cacheDSN)
+	assert.NoError(t, err)
+	defer cache.Close()
+	count, err := cache.CountProfits("SBER")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestParseCutoff(t *testing.T) {
+	cutoff, err := parseCutoff("2024-03-01")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), cutoff)
+
+	cutoff, err = parseCutoff("30")
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().AddDate(0, 0, -30), cutoff, time.Minute)
+
+	_, err = parseCutoff("last week")
+	assert.Error(t, err)
+}
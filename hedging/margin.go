@@ -0,0 +1,138 @@
+package hedging
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/TuliMyrskyTaivas/hedging/moex"
+	"golang.org/x/text/message"
+	"gonum.org/v1/gonum/stat"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// Confidence levels of variation margin estimates by default
+const defaultConfidenceLevels = "0.95,0.99"
+
+// One-day variation margin loss not exceeded with the confidence
+type marginSwing struct {
+	confidence float64
+	normal     float64 // by normal distribution of returns
+	historical float64 // by empirical quantile of returns
+}
+
+// Capital tied up by the futures position
+type marginEstimate struct {
+	initialMargin float64
+	lowLimit      float64
+	highLimit     float64
+	limitLoss     float64 // variation margin if the price hits the adverse limit, NaN if limits are unknown
+	swings        []marginSwing
+}
+
+// ////////////////////////////////////////////////////////
+// Parse comma separated confidence levels
+// ////////////////////////////////////////////////////////
+func parseConfidenceLevels(value string) ([]float64, error) {
+	if value == "" {
+		value = defaultConfidenceLevels
+	}
+
+	var levels []float64
+	for _, item := range strings.Split(value, ",") {
+		level, err := strconv.ParseFloat(strings.TrimSpace(item), 64)
+		if err != nil || level <= 0 || level >= 1 {
+			return nil, fmt.Errorf("confidence level must be a number in range (0, 1), got %q", item)
+		}
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
+
+// ////////////////////////////////////////////////////////
+// Daily close-to-close returns of the future. Variation
+// margin is settled daily whatever the sampling of returns
+// of the hedge ratio is, and its tails must not be cleaned
+// ////////////////////////////////////////////////////////
+func marginReturns(secid string, history []moex.HistoryItem) []float64 {
+	_, returns := historyReturns(secid, history, defaultReturnSpec)
+	return returns
+}
+
+// ////////////////////////////////////////////////////////
+// Estimate initial margin and one-day variation margin of
+// the position in contracts, negative for short position.
+// Returns are daily returns of the future the position is
+// in, weights apply to both normal and historical swings
+// ////////////////////////////////////////////////////////
+func estimateMargin(info moex.FutureInfo, contracts int, price float64, returns []float64, weights []float64,
+	levels []float64) (marginEstimate, error) {
+	count := math.Abs(float64(contracts))
+	notional := count * price / info.Minstep * info.Stepprice
+	estimate := marginEstimate{
+		initialMargin: count * info.Initialmargin,
+		lowLimit:      info.Lowlimit,
+		highLimit:     info.Highlimit,
+		limitLoss:     math.NaN(),
+	}
+	if len(returns) == 0 {
+		return estimate, fmt.Errorf("no daily returns of %s to estimate variation margin", info.Secid)
+	}
+
+	// Short position loses when the price grows, long one when it falls
+	adverseLimit := info.Lowlimit
+	if contracts < 0 {
+		adverseLimit = info.Highlimit
+	}
+	if adverseLimit != 0 {
+		estimate.limitLoss = math.Abs(adverseLimit-price) / info.Minstep * info.Stepprice * count
+	}
+
+	// Empirical quantile needs sorted returns with their weights
+	order := make([]int, len(returns))
+	for idx := range order {
+		order[idx] = idx
+	}
+	sort.SliceStable(order, func(i, j int) bool { return returns[order[i]] < returns[order[j]] })
+	sorted := make([]float64, len(returns))
+	var sortedWeights []float64
+	if weights != nil {
+		sortedWeights = make([]float64, len(weights))
+	}
+	for idx, position := range order {
+		sorted[idx] = returns[position]
+		if weights != nil {
+			sortedWeights[idx] = weights[position]
+		}
+	}
+
+	stdDev := stat.StdDev(returns, weights)
+	for _, level := range levels {
+		swing := marginSwing{confidence: level, normal: distuv.UnitNormal.Quantile(level) * stdDev * notional}
+		if contracts < 0 {
+			swing.historical = stat.Quantile(level, stat.Empirical, sorted, sortedWeights) * notional
+		} else {
+			swing.historical = -stat.Quantile(1-level, stat.Empirical, sorted, sortedWeights) * notional
+		}
+		estimate.swings = append(estimate.swings, swing)
+	}
+	return estimate, nil
+}
+
+// ////////////////////////////////////////////////////////
+// Print margin and capital requirement of the hedge
+// ////////////////////////////////////////////////////////
+func printMarginEstimate(printer *message.Printer, future string, estimate marginEstimate) {
+	printer.Printf("Initial margin of the hedge is %.2f RUB\n", estimate.initialMargin)
+	if !math.IsNaN(estimate.limitLoss) {
+		printer.Printf("Price limits of %s are %f - %f, variation margin at the adverse limit is %.2f RUB\n",
+			future, estimate.lowLimit, estimate.highLimit, estimate.limitLoss)
+	}
+	printer.Printf("\t%-10s %16s %16s %16s\n", "Confidence", "VM (normal)", "VM (historical)", "Capital")
+	for _, swing := range estimate.swings {
+		capital := estimate.initialMargin + math.Max(swing.normal, swing.historical)
+		printer.Printf("\t%-10.3f %16.2f %16.2f %16.2f\n", swing.confidence, swing.normal, swing.historical, capital)
+	}
+}
@@ -0,0 +1,78 @@
+package hedging
+
+import (
+	"math"
+	"testing"
+
+	"github.com/TuliMyrskyTaivas/hedging/moex"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseConfidenceLevels(t *testing.T) {
+	levels, err := parseConfidenceLevels("")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{0.95, 0.99}, levels)
+
+	levels, err = parseConfidenceLevels("0.9, 0.975")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{0.9, 0.975}, levels)
+
+	_, err = parseConfidenceLevels("95")
+	assert.Error(t, err)
+	_, err = parseConfidenceLevels("high")
+	assert.Error(t, err)
+}
+
+func TestEstimateMargin(t *testing.T) {
+	info := moex.FutureInfo{Minstep: 1, Stepprice: 1, Initialmargin: 5000, Lowlimit: 90000, Highlimit: 94000}
+	returns := []float64{-0.02, -0.01, 0, 0.01, 0.03}
+
+	short, err := estimateMargin(info, -10, 92000, returns, nil, []float64{0.99})
+	assert.NoError(t, err)
+	assert.Equal(t, 50000.0, short.initialMargin)
+	assert.Equal(t, 20000.0, short.limitLoss)
+	assert.Len(t, short.swings, 1)
+	assert.InDelta(t, 2.326348*0.0192353841*920000, short.swings[0].normal, 1)
+	assert.InDelta(t, 0.03*920000, short.swings[0].historical, 1e-6)
+
+	long, err := estimateMargin(info, 10, 92000, returns, nil, []float64{0.99})
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.02*920000, long.swings[0].historical, 1e-6)
+	assert.Equal(t, 20000.0, long.limitLoss)
+
+	noLimits, err := estimateMargin(moex.FutureInfo{Minstep: 1, Stepprice: 1}, -1, 100, returns, nil, nil)
+	assert.NoError(t, err)
+	assert.True(t, math.IsNaN(noLimits.limitLoss))
+}
+
+func TestEstimateMarginWeighted(t *testing.T) {
+	info := moex.FutureInfo{Minstep: 1, Stepprice: 1}
+	returns := []float64{0.05, -0.01, 0.01, 0, 0.02}
+
+	// Recent small returns dominate, the old large one drops out of the historical tail
+	weights := []float64{0.01, 1, 1, 1, 1}
+	weighted, err := estimateMargin(info, -1, 100, returns, weights, []float64{0.9})
+	assert.NoError(t, err)
+	equal, err := estimateMargin(info, -1, 100, returns, nil, []float64{0.9})
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.02*100, weighted.swings[0].historical, 1e-9)
+	assert.InDelta(t, 0.05*100, equal.swings[0].historical, 1e-9)
+	assert.Less(t, weighted.swings[0].normal, equal.swings[0].normal)
+}
+
+func TestEstimateMarginWithoutReturns(t *testing.T) {
+	_, err := estimateMargin(moex.FutureInfo{Secid: "SiZ4", Minstep: 1, Stepprice: 1}, -1, 100, nil, nil, []float64{0.99})
+	assert.EqualError(t, err, "no daily returns of SiZ4 to estimate variation margin")
+}
+
+func TestMarginReturnsAreDaily(t *testing.T) {
+	history := []moex.HistoryItem{
+		{Tradedate: "2024-01-29", Open: 99, Close: 100},
+		{Tradedate: "2024-01-30", Open: 101, Close: 110},
+		{Tradedate: "2024-01-31", Open: 108, Close: 99},
+	}
+	returns := marginReturns("SiH4", history)
+	assert.Len(t, returns, 2)
+	assert.InDelta(t, 0.1, returns[0], 1e-12)
+	assert.InDelta(t, -0.1, returns[1], 1e-12)
+}
@@ -0,0 +1,167 @@
+package hedging
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Cache kept in memory, for tests and runs which should leave no files
+type memoryCache struct {
+	mutex    sync.Mutex
+	profits  map[string]map[string]float64 // ticker -> date -> profit
+	metadata map[string]memoryMetadata     // kind/key -> value
+}
+
+type memoryMetadata struct {
+	value   []byte
+	fetched time.Time
+}
+
+func newMemoryCache() *memoryCache {
+	return &memoryCache{
+		profits:  make(map[string]map[string]float64),
+		metadata: make(map[string]memoryMetadata),
+	}
+}
+
+// Dates of the ticker in ascending order, must be called under lock
+func (cache *memoryCache) sortedDates(ticker string) []string {
+	dates := make([]string, 0, len(cache.profits[ticker]))
+	for date := range cache.profits[ticker] {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+	return dates
+}
+
+func (cache *memoryCache) GetAvailableRange(asset string) (time.Time, time.Time, error) {
+	cache.mutex.Lock()
+	dates := cache.sortedDates(asset)
+	cache.mutex.Unlock()
+
+	if len(dates) == 0 {
+		return time.Time{}, time.Time{}, fmt.Errorf("no profits of %s in cache", asset)
+	}
+
+	const TimeFormat = "2006-01-02"
+	fromTime, err := time.Parse(TimeFormat, dates[0])
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	tillTime, err := time.Parse(TimeFormat, dates[len(dates)-1])
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	return fromTime, tillTime, nil
+}
+
+func (cache *memoryCache) AddProfits(ticker string, dates []string, profits []float64) error {
+	if len(dates) != len(profits) {
+		panic("the sizes of the sequences of dates and profits do not match")
+	}
+	return cache.addProfitsBatches([]profitsBatch{{ticker: ticker, dates: dates, profits: profits}})
+}
+
+func (cache *memoryCache) addProfitsBatches(batches []profitsBatch) error {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	for _, batch := range batches {
+		profits, ok := cache.profits[batch.ticker]
+		if !ok {
+			profits = make(map[string]float64)
+			cache.profits[batch.ticker] = profits
+		}
+		for idx, date := range batch.dates {
+			profits[date] = batch.profits[idx]
+		}
+	}
+	return nil
+}
+
+func (cache *memoryCache) GetTickers() ([]string, error) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	tickers := make([]string, 0, len(cache.profits))
+	for ticker := range cache.profits {
+		tickers = append(tickers, ticker)
+	}
+	sort.Strings(tickers)
+	return tickers, nil
+}
+
+func (cache *memoryCache) GetProfits(ticker string) ([]string, []float64, error) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	dates := cache.sortedDates(ticker)
+	profits := make([]float64, 0, len(dates))
+	for _, date := range dates {
+		profits = append(profits, cache.profits[ticker][date])
+	}
+	return dates, profits, nil
+}
+
+func (cache *memoryCache) CountProfits(ticker string) (int, error) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	return len(cache.profits[ticker]), nil
+}
+
+func (cache *memoryCache) PurgeTicker(ticker string) (int64, error) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	removed := int64(len(cache.profits[ticker]))
+	delete(cache.profits, ticker)
+	return removed, nil
+}
+
+func (cache *memoryCache) PurgeOlderThan(date time.Time) (int64, error) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cutoff := date.Format("2006-01-02")
+	var removed int64
+	for ticker, profits := range cache.profits {
+		for day := range profits {
+			if day < cutoff {
+				delete(profits, day)
+				removed++
+			}
+		}
+		if len(profits) == 0 {
+			delete(cache.profits, ticker)
+		}
+	}
+	return removed, nil
+}
+
+func (cache *memoryCache) GetMetadata(kind string, key string) ([]byte, time.Time, error) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	metadata := cache.metadata[kind+"/"+key]
+	return metadata.value, metadata.fetched, nil
+}
+
+func (cache *memoryCache) PutMetadata(kind string, key string, value []byte) error {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.metadata[kind+"/"+key] = memoryMetadata{value: value, fetched: time.Now()}
+	return nil
+}
+
+func (cache *memoryCache) Vacuum() error {
+	return nil
+}
+
+func (cache *memoryCache) Close() error {
+	return nil
+}
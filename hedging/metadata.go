@@ -0,0 +1,118 @@
+package hedging
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/TuliMyrskyTaivas/hedging/moex"
+)
+
+// How long asset metadata is considered fresh by default
+const DefaultMetadataTTL = 24 * time.Hour
+
+// Kinds of metadata kept in the cache
+const (
+	assetMetadata  = "asset"
+	futureMetadata = "future"
+)
+
+// MOEX trading dates are in Moscow time
+var moscowTime = time.FixedZone("MSK", 3*60*60)
+
+// Queries MOEX on asset metadata, keeping the answers in the cache
+type assetResolver struct {
+	cache   Cache // nil disables caching
+	ttl     time.Duration
+	refresh bool       // ignore cached values, but still update them
+	writes  sync.Mutex // assets are resolved concurrently, their metadata is saved one at a time
+}
+
+// ////////////////////////////////////////////////////////
+// Constructor
+// ////////////////////////////////////////////////////////
+func newAssetResolver(cache Cache, command Command) *assetResolver {
+	ttl := command.MetadataTTL
+	if ttl == 0 {
+		ttl = DefaultMetadataTTL
+	}
+	return &assetResolver{cache: cache, ttl: ttl, refresh: command.Refresh}
+}
+
+// ////////////////////////////////////////////////////////
+// Get info on MOEX asset
+// ////////////////////////////////////////////////////////
+func (resolver *assetResolver) GetAsset(secid string) (moex.Asset, error) {
+	return getCachedMetadata(resolver, assetMetadata, secid, moex.GetAsset)
+}
+
+// ////////////////////////////////////////////////////////
+// Get specification of MOEX future
+// ////////////////////////////////////////////////////////
+func (resolver *assetResolver) GetFutureInfo(secid string) (moex.FutureInfo, error) {
+	return getCachedMetadata(resolver, futureMetadata, secid, moex.GetFutureInfo)
+}
+
+// ////////////////////////////////////////////////////////
+// Get info on the underlying asset of MOEX future
+// ////////////////////////////////////////////////////////
+func (resolver *assetResolver) GetFutureUnderlyingAsset(future moex.Asset) (moex.Asset, error) {
+	info, err := resolver.GetFutureInfo(future.Secid)
+	if err != nil {
+		return moex.Asset{}, err
+	}
+	return resolver.GetAsset(info.UnderlyingAssetCode())
+}
+
+// ////////////////////////////////////////////////////////
+// Take metadata from the cache if it is fresh enough,
+// otherwise query MOEX and store the answer
+// ////////////////////////////////////////////////////////
+func getCachedMetadata[T any](resolver *assetResolver, kind string, key string, fetch func(string) (T, error)) (T, error) {
+	var result T
+	if resolver.cache == nil {
+		return fetch(key)
+	}
+
+	if !resolver.refresh {
+		value, fetched, err := resolver.cache.GetMetadata(kind, key)
+		if err != nil {
+			return result, err
+		}
+		if value != nil && time.Since(fetched) < resolver.ttl {
+			if err = json.Unmarshal(value, &result); err == nil {
+				slog.Debug(fmt.Sprintf("%s %s is taken from cache (fetched at %s)", kind, key, fetched.Format(time.DateTime)))
+				return result, nil
+			}
+			slog.Debug(fmt.Sprintf("failed to parse cached %s %s: %s", kind, key, err))
+		}
+	}
+
+	result, err := fetch(key)
+	if err != nil {
+		return result, err
+	}
+
+	// Metadata is fetched already, failure to cache it only costs a query next time
+	value, err := json.Marshal(result)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("failed to encode %s %s for cache: %s", kind, key, err))
+		return result, nil
+	}
+	resolver.writes.Lock()
+	defer resolver.writes.Unlock()
+	if err = resolver.cache.PutMetadata(kind, key, value); err != nil {
+		slog.Warn(fmt.Sprintf("failed to save %s %s to cache: %s", kind, key, err))
+	}
+	return result, nil
+}
+
+// ////////////////////////////////////////////////////////
+// The bar of the current trading day is still changing,
+// it must not be cached and should be fetched again
+// ////////////////////////////////////////////////////////
+func isVolatileBar(tradedate string) bool {
+	return tradedate >= time.Now().In(moscowTime).Format("2006-01-02")
+}
@@ -0,0 +1,120 @@
+package hedging
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/TuliMyrskyTaivas/hedging/moex"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCachedMetadata(t *testing.T) {
+	cache := newMemoryCache()
+	resolver := &assetResolver{cache: cache, ttl: time.Hour}
+
+	calls := 0
+	fetch := func(secid string) (moex.Asset, error) {
+		calls++
+		return moex.Asset{Secid: secid, HistoryFrom: "2010-01-04"}, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		asset, err := getCachedMetadata(resolver, assetMetadata, "SBER", fetch)
+		assert.NoError(t, err)
+		assert.Equal(t, "SBER", asset.Secid)
+		assert.Equal(t, "2010-01-04", asset.HistoryFrom)
+	}
+	assert.Equal(t, 1, calls)
+
+	// Forced revalidation
+	resolver.refresh = true
+	_, err := getCachedMetadata(resolver, assetMetadata, "SBER", fetch)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	// Expired value
+	resolver.refresh = false
+	resolver.ttl = 0
+	_, err = getCachedMetadata(resolver, assetMetadata, "SBER", fetch)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestGetCachedMetadataErrors(t *testing.T) {
+	cache := newMemoryCache()
+	resolver := &assetResolver{cache: cache, ttl: time.Hour}
+
+	fetch := func(secid string) (moex.FutureInfo, error) {
+		return moex.FutureInfo{}, fmt.Errorf("future %s not found on MOEX", secid)
+	}
+
+	_, err := getCachedMetadata(resolver, futureMetadata, "XXZ4", fetch)
+	assert.EqualError(t, err, "future XXZ4 not found on MOEX")
+
+	// Failures are not cached
+	value, _, err := cache.GetMetadata(futureMetadata, "XXZ4")
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+}
+
+// Cache failing to save metadata and counting writes running at once
+type failingMetadataCache struct {
+	Cache
+	active  atomic.Int32
+	overlap atomic.Bool
+}
+
+func (cache *failingMetadataCache) PutMetadata(kind string, key string, value []byte) error {
+	if cache.active.Add(1) > 1 {
+		cache.overlap.Store(true)
+	}
+	defer cache.active.Add(-1)
+	time.Sleep(time.Millisecond)
+	return fmt.Errorf("database is locked")
+}
+
+func TestGetCachedMetadataSaveFailure(t *testing.T) {
+	cache := &failingMetadataCache{Cache: newMemoryCache()}
+	resolver := newAssetResolver(cache, Command{})
+	fetch := func(secid string) (moex.Asset, error) {
+		return moex.Asset{Secid: secid}, nil
+	}
+
+	var wait sync.WaitGroup
+	for _, secid := range []string{"SBER", "GAZP", "LKOH", "ROSN", "GMKN"} {
+		wait.Add(1)
+		go func(secid string) {
+			defer wait.Done()
+			asset, err := getCachedMetadata(resolver, assetMetadata, secid, fetch)
+			assert.NoError(t, err)
+			assert.Equal(t, secid, asset.Secid)
+		}(secid)
+	}
+	wait.Wait()
+	assert.False(t, cache.overlap.Load(), "metadata writes must not run concurrently")
+}
+
+func TestGetCachedMetadataWithoutCache(t *testing.T) {
+	resolver := newAssetResolver(nil, Command{})
+	assert.Equal(t, DefaultMetadataTTL, resolver.ttl)
+
+	calls := 0
+	fetch := func(secid string) (moex.Asset, error) {
+		calls++
+		return moex.Asset{Secid: secid}, nil
+	}
+	for i := 0; i < 2; i++ {
+		_, err := getCachedMetadata(resolver, assetMetadata, "SBER", fetch)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 2, calls)
+}
+
+func TestIsVolatileBar(t *testing.T) {
+	today := time.Now().In(moscowTime)
+	assert.True(t, isVolatileBar(today.Format("2006-01-02")))
+	assert.False(t, isVolatileBar(today.AddDate(0, 0, -1).Format("2006-01-02")))
+}
@@ -0,0 +1,169 @@
+package hedging
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+// Methods to detect outliers in return series
+const (
+	noOutliers     = "none"
+	zScoreOutliers = "zscore" // distance from the mean in standard deviations
+	madOutliers    = "mad"    // distance from the median in scaled median absolute deviations
+)
+
+// Ways to treat detected outliers
+const (
+	winsorizeOutliers = "winsorize" // clip to the detection limit
+	removeOutliers    = "remove"    // drop the observation from both series
+)
+
+// Default detection limits of the methods
+const (
+	defaultZScoreLimit = 3.0
+	defaultMADLimit    = 3.5
+)
+
+// Consistency constant making MAD an estimate of standard deviation on normal data
+const madScale = 0.6745
+
+// Settings of the cleaning of return series
+type cleaningSpec struct {
+	method string
+	limit  float64
+	action string
+}
+
+// What was changed in the return series of a ticker
+type cleaningSummary struct {
+	ticker string
+	dates  []string // dates of detected outliers
+}
+
+// ////////////////////////////////////////////////////////
+// Get settings of the cleaning from the command
+// ////////////////////////////////////////////////////////
+func newCleaningSpec(command Command) (cleaningSpec, error) {
+	spec := cleaningSpec{method: command.Outliers, limit: command.OutlierLimit, action: command.OutlierAction}
+	if spec.method == "" {
+		spec.method = noOutliers
+	}
+	if spec.action == "" {
+		spec.action = winsorizeOutliers
+	}
+
+	switch spec.method {
+	case noOutliers:
+	case zScoreOutliers:
+		if spec.limit == 0 {
+			spec.limit = defaultZScoreLimit
+		}
+	case madOutliers:
+		if spec.limit == 0 {
+			spec.limit = defaultMADLimit
+		}
+	default:
+		return spec, fmt.Errorf("unknown outlier detection method %s, use %s, %s or %s", spec.method, noOutliers, zScoreOutliers, madOutliers)
+	}
+
+	if spec.limit < 0 {
+		return spec, fmt.Errorf("outlier limit must be positive")
+	}
+	if spec.action != winsorizeOutliers && spec.action != removeOutliers {
+		return spec, fmt.Errorf("unknown outlier action %s, use %s or %s", spec.action, winsorizeOutliers, removeOutliers)
+	}
+	return spec, nil
+}
+
+// ////////////////////////////////////////////////////////
+// Get the lowest and the highest values which are not
+// considered outliers, bounds are infinite if the series
+// has no spread
+// ////////////////////////////////////////////////////////
+func outlierBounds(values []float64, spec cleaningSpec) (float64, float64) {
+	var center, scale float64
+	if spec.method == zScoreOutliers {
+		center, scale = stat.MeanStdDev(values, nil)
+	} else {
+		center, scale = median(values), medianAbsoluteDeviation(values)/madScale
+	}
+	if scale == 0 || math.IsNaN(scale) {
+		// Most values are equal, outliers can not be told apart
+		return math.Inf(-1), math.Inf(1)
+	}
+	return center - spec.limit*scale, center + spec.limit*scale
+}
+
+// ////////////////////////////////////////////////////////
+// Detect outliers in both return series and winsorize or
+// remove them
+// ////////////////////////////////////////////////////////
+func cleanReturns(spec cleaningSpec, dates []string, tickerA string, a []float64, tickerB string, b []float64) (
+	[]string, []float64, []float64, []cleaningSummary) {
+	dates, series, summaries := cleanSeries(spec, dates, []string{tickerA, tickerB}, [][]float64{a, b})
+	return dates, series[0], series[1], summaries
+}
+
+// ////////////////////////////////////////////////////////
+// Detect outliers in aligned return series and winsorize
+// or remove them. Removal drops the date from all series
+// to keep them aligned
+// ////////////////////////////////////////////////////////
+func cleanSeries(spec cleaningSpec, dates []string, tickers []string, series [][]float64) (
+	[]string, [][]float64, []cleaningSummary) {
+	if spec.method == noOutliers || len(dates) == 0 {
+		return dates, series, nil
+	}
+
+	summaries := make([]cleaningSummary, len(series))
+	cleaned := make([][]float64, len(series))
+	outlier := make([]bool, len(dates))
+	for idx := range series {
+		summaries[idx].ticker = tickers[idx]
+		cleaned[idx] = append([]float64(nil), series[idx]...)
+		low, high := outlierBounds(cleaned[idx], spec)
+		for day, value := range cleaned[idx] {
+			if value >= low && value <= high {
+				continue
+			}
+			summaries[idx].dates = append(summaries[idx].dates, dates[day])
+			outlier[day] = true
+			cleaned[idx][day] = math.Max(low, math.Min(high, value))
+		}
+	}
+
+	if spec.action == winsorizeOutliers {
+		return dates, cleaned, summaries
+	}
+
+	var keptDates []string
+	kept := make([][]float64, len(series))
+	for day := range dates {
+		if outlier[day] {
+			continue
+		}
+		keptDates = append(keptDates, dates[day])
+		for idx := range series {
+			kept[idx] = append(kept[idx], series[idx][day])
+		}
+	}
+	return keptDates, kept, summaries
+}
+
+// ////////////////////////////////////////////////////////
+// Describe what the cleaning changed in the series
+// ////////////////////////////////////////////////////////
+func describeCleaning(spec cleaningSpec, summary cleaningSummary) string {
+	if len(summary.dates) == 0 {
+		return fmt.Sprintf("no outliers in returns of %s", summary.ticker)
+	}
+	action := "winsorized"
+	if spec.action == removeOutliers {
+		action = "removed"
+	}
+	return fmt.Sprintf("%d outliers in returns of %s beyond %.1f (%s) %s: %s", len(summary.dates), summary.ticker,
+		spec.limit, spec.method, action, strings.Join(summary.dates, ", "))
+}
@@ -0,0 +1,76 @@
+package hedging
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func outlierSeries() ([]string, []float64, []float64) {
+	dates := []string{"2024-01-01", "2024-01-02", "2024-01-03", "2024-01-04", "2024-01-05", "2024-01-08", "2024-01-09"}
+	a := []float64{0.01, -0.01, 0.02, -0.02, 0.01, 0.5, -0.01}
+	b := []float64{0.01, -0.01, 0.015, -0.012, 0.008, 0.011, -0.009}
+	return dates, a, b
+}
+
+func TestNewCleaningSpec(t *testing.T) {
+	spec, err := newCleaningSpec(Command{})
+	assert.NoError(t, err)
+	assert.Equal(t, noOutliers, spec.method)
+
+	spec, err = newCleaningSpec(Command{Outliers: "mad"})
+	assert.NoError(t, err)
+	assert.Equal(t, cleaningSpec{method: madOutliers, limit: defaultMADLimit, action: winsorizeOutliers}, spec)
+
+	spec, err = newCleaningSpec(Command{Outliers: "zscore", OutlierLimit: 2, OutlierAction: "remove"})
+	assert.NoError(t, err)
+	assert.Equal(t, cleaningSpec{method: zScoreOutliers, limit: 2, action: removeOutliers}, spec)
+
+	_, err = newCleaningSpec(Command{Outliers: "iqr"})
+	assert.Error(t, err)
+	_, err = newCleaningSpec(Command{Outliers: "mad", OutlierAction: "ignore"})
+	assert.Error(t, err)
+}
+
+func TestWinsorizeOutliers(t *testing.T) {
+	dates, a, b := outlierSeries()
+	spec := cleaningSpec{method: madOutliers, limit: defaultMADLimit, action: winsorizeOutliers}
+
+	cleanDates, cleanA, cleanB, summaries := cleanReturns(spec, dates, "SBER", a, "IMOEX", b)
+	assert.Equal(t, dates, cleanDates)
+	assert.Equal(t, b, cleanB)
+	assert.Equal(t, 0.5, a[5], "input must not be modified")
+
+	_, high := outlierBounds(a, spec)
+	assert.InDelta(t, high, cleanA[5], 1e-12)
+	assert.Less(t, cleanA[5], 0.2)
+	assert.Equal(t, []string{"2024-01-08"}, summaries[0].dates)
+	assert.Empty(t, summaries[1].dates)
+	assert.Contains(t, describeCleaning(spec, summaries[0]), "1 outliers in returns of SBER")
+	assert.Contains(t, describeCleaning(spec, summaries[0]), "winsorized")
+}
+
+func TestRemoveOutliers(t *testing.T) {
+	dates, a, b := outlierSeries()
+	spec := cleaningSpec{method: madOutliers, limit: defaultMADLimit, action: removeOutliers}
+
+	cleanDates, cleanA, cleanB, _ := cleanReturns(spec, dates, "SBER", a, "IMOEX", b)
+	assert.Len(t, cleanDates, 6)
+	assert.NotContains(t, cleanDates, "2024-01-08")
+	assert.Len(t, cleanA, 6)
+	assert.Len(t, cleanB, 6)
+}
+
+func TestOutlierBoundsWithoutSpread(t *testing.T) {
+	low, high := outlierBounds([]float64{0.01, 0.01, 0.01, 0.02}, cleaningSpec{method: madOutliers, limit: defaultMADLimit})
+	assert.True(t, math.IsInf(low, -1))
+	assert.True(t, math.IsInf(high, 1))
+}
+
+func TestNoCleaning(t *testing.T) {
+	dates, a, b := outlierSeries()
+	_, cleanA, _, summaries := cleanReturns(cleaningSpec{method: noOutliers}, dates, "SBER", a, "IMOEX", b)
+	assert.Equal(t, a, cleanA)
+	assert.Nil(t, summaries)
+}
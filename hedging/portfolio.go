@@ -0,0 +1,397 @@
+package hedging
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TuliMyrskyTaivas/hedging/moex"
+	"golang.org/x/text/message"
+	"gopkg.in/yaml.v3"
+)
+
+// Position of the portfolio, either quantity or weight is set
+type Position struct {
+	Ticker   string  `yaml:"ticker"`
+	Quantity float64 `yaml:"quantity"` // number of shares or units
+	Weight   float64 `yaml:"weight"`   // share of the portfolio value
+}
+
+// Positions read from the portfolio file
+type Portfolio struct {
+	Positions []Position `yaml:"positions"`
+}
+
+// ///////////////////////////////////////////////////////////////////
+// Read portfolio from CSV file with ticker,quantity or ticker,weight
+// columns, or from YAML file with the list of positions
+// ///////////////////////////////////////////////////////////////////
+func LoadPortfolio(filename string) (Portfolio, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return Portfolio{}, err
+	}
+	defer file.Close()
+
+	var portfolio Portfolio
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		portfolio, err = readPortfolioCSV(file)
+	case ".yaml", ".yml":
+		err = yaml.NewDecoder(file).Decode(&portfolio)
+	default:
+		return portfolio, fmt.Errorf("unknown format of portfolio file %s, use .csv or .yaml", filename)
+	}
+	if err != nil {
+		return portfolio, fmt.Errorf("failed to read portfolio file %s: %s", filename, err)
+	}
+
+	if err = portfolio.validate(); err != nil {
+		return portfolio, fmt.Errorf("invalid portfolio file %s: %s", filename, err)
+	}
+	return portfolio, nil
+}
+
+// ///////////////////////////////////////////////////////////////////
+// Read positions from CSV file with header
+// ///////////////////////////////////////////////////////////////////
+func readPortfolioCSV(input io.Reader) (Portfolio, error) {
+	reader := csv.NewReader(input)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return Portfolio{}, err
+	}
+
+	columns := make(map[string]int)
+	for idx, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = idx
+	}
+	if _, ok := columns["ticker"]; !ok {
+		return Portfolio{}, fmt.Errorf("ticker column is missing")
+	}
+
+	var portfolio Portfolio
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return portfolio, err
+		}
+
+		position := Position{Ticker: record[columns["ticker"]]}
+		for name, field := range map[string]*float64{"quantity": &position.Quantity, "weight": &position.Weight} {
+			idx, ok := columns[name]
+			if !ok || len(record[idx]) == 0 {
+				continue
+			}
+			if *field, err = strconv.ParseFloat(record[idx], 64); err != nil {
+				return portfolio, fmt.Errorf("wrong %s of %s: %s", name, position.Ticker, err)
+			}
+		}
+		portfolio.Positions = append(portfolio.Positions, position)
+	}
+	return portfolio, nil
+}
+
+// ///////////////////////////////////////////////////////////////////
+// Check that all positions are given either by quantities or weights
+// ///////////////////////////////////////////////////////////////////
+func (portfolio Portfolio) validate() error {
+	if len(portfolio.Positions) == 0 {
+		return fmt.Errorf("no positions")
+	}
+	for _, position := range portfolio.Positions {
+		if len(position.Ticker) == 0 {
+			return fmt.Errorf("position without ticker")
+		}
+		if (position.Quantity == 0) == (position.Weight == 0) {
+			return fmt.Errorf("either quantity or weight of %s must be set", position.Ticker)
+		}
+		if (position.Quantity == 0) != portfolio.byWeights() {
+			return fmt.Errorf("positions must be all given by quantities or all by weights")
+		}
+	}
+	return nil
+}
+
+// ///////////////////////////////////////////////////////////////////
+// Whether positions are given by weights instead of quantities
+// ///////////////////////////////////////////////////////////////////
+func (portfolio Portfolio) byWeights() bool {
+	return portfolio.Positions[0].Weight != 0
+}
+
+// Returns of the portfolio aligned with returns of the index
+type portfolioReturns struct {
+	dates        []string
+	returns      []float64
+	indexReturns []float64
+	weights      []float64 // weights of positions in the portfolio
+	value        float64   // RUB value of the portfolio, 0 if it is given by weights
+	indexHistory []moex.HistoryItem
+}
+
+// ///////////////////////////////////////////////////////////////////
+// Get returns of the portfolio with constant weights of positions on
+// the dates when all positions and the index were traded
+// ///////////////////////////////////////////////////////////////////
+func getPortfolioReturns(resolver *assetResolver, portfolio Portfolio, index moex.Asset, depthMonth int,
+	spec returnSpec) (portfolioReturns, error) {
+	var result portfolioReturns
+
+	assets := make([]moex.Asset, len(portfolio.Positions))
+	for idx, position := range portfolio.Positions {
+		asset, err := resolver.GetAsset(position.Ticker)
+		if err != nil {
+			return result, err
+		}
+		assets[idx] = asset
+	}
+
+	historyFrom, historyTo := historyRange(depthMonth, append([]moex.Asset{index}, assets...)...)
+	histories, err := fetchHistories(append([]moex.Asset{index}, assets...), historyFrom, historyTo)
+	if err != nil {
+		return result, err
+	}
+
+	// Index goes first, then positions of the portfolio
+	indexHistory := histories[0]
+	for idx := 1; idx < len(histories); idx++ {
+		indexHistory, histories[idx] = alignHistories(indexHistory, histories[idx])
+	}
+	result.indexHistory = indexHistory
+
+	var dates [][]string
+	var series [][]float64
+	for idx, asset := range append([]moex.Asset{index}, assets...) {
+		_, histories[idx] = alignHistories(indexHistory, histories[idx])
+		assetDates, assetReturns := historyReturns(asset.Secid, histories[idx], spec)
+		dates, series = append(dates, assetDates), append(series, assetReturns)
+	}
+	commonDates, aligned := alignSeries(dates, series)
+
+	// Weights are taken from the current values of positions
+	var total float64
+	for idx, position := range portfolio.Positions {
+		weight := position.Weight
+		if !portfolio.byWeights() {
+			weight = position.Quantity * lastPrice(histories[idx+1])
+			result.value += weight
+		}
+		result.weights = append(result.weights, weight)
+		total += weight
+	}
+	if total == 0 {
+		return result, fmt.Errorf("portfolio has zero value")
+	}
+	for idx := range result.weights {
+		result.weights[idx] /= total
+	}
+
+	result.dates = commonDates
+	result.indexReturns = aligned[0]
+	result.returns = make([]float64, len(commonDates))
+	for idx, weight := range result.weights {
+		for day, value := range aligned[idx+1] {
+			result.returns[day] += weight * value
+		}
+	}
+	return result, nil
+}
+
+// ///////////////////////////////////////////////////////////////////
+// Get histories of assets concurrently
+// ///////////////////////////////////////////////////////////////////
+func fetchHistories(assets []moex.Asset, from time.Time, to time.Time) ([][]moex.HistoryItem, error) {
+	histories := make([][]moex.HistoryItem, len(assets))
+	errs := make([]error, len(assets))
+
+	var wait sync.WaitGroup
+	for idx := range assets {
+		wait.Add(1)
+		go func(idx int) {
+			defer wait.Done()
+			histories[idx], errs[idx] = assets[idx].GetHistory(from, to)
+		}(idx)
+	}
+	wait.Wait()
+	return histories, errors.Join(errs...)
+}
+
+// ///////////////////////////////////////////////////////////////////
+// Print weights of positions in the portfolio
+// ///////////////////////////////////////////////////////////////////
+func printPortfolio(printer *message.Printer, portfolio Portfolio, returns portfolioReturns) {
+	printer.Printf("Portfolio of %d positions", len(portfolio.Positions))
+	if returns.value != 0 {
+		printer.Printf(" worth %.2f RUB", returns.value)
+	}
+	printer.Printf(", %d common trading days:\n", len(returns.dates))
+	for idx, position := range portfolio.Positions {
+		printer.Printf("\t%-12s %8.2f%%\n", position.Ticker, returns.weights[idx]*100)
+	}
+}
+
+// ///////////////////////////////////////////////////////////////////
+// Calculate beta of the portfolio on the index and the exposure to
+// the index needed to reach the target beta
+// ///////////////////////////////////////////////////////////////////
+func (calculator *betaCalculator) executePortfolio(command Command, spec returnSpec, cleaning cleaningSpec) error {
+	portfolio, err := LoadPortfolio(command.Portfolio)
+	if err != nil {
+		return err
+	}
+
+	resolver := newAssetResolver(calculator.cache, command)
+	index, err := resolver.GetAsset(command.Hedge)
+	if err != nil {
+		return err
+	}
+
+	returns, err := getPortfolioReturns(resolver, portfolio, index, command.HistoryDepth, spec)
+	if err != nil {
+		return err
+	}
+	stats, regimes := portfolioRegression(command, cleaning, index.Secid, returns)
+	if err = reportPortfolio(command, index.Secid, stats, regimes); err != nil {
+		return err
+	}
+
+	printer, err := GetPrinter()
+	if err != nil {
+		return err
+	}
+	printPortfolio(printer, portfolio, returns)
+	printer.Printf("Beta is estimated on %s taken with %s\n", spec, describeWeighting(decayFactor(command)))
+	printer.Printf("Beta coefficient for last %d month of the portfolio on %s is %f\n", command.HistoryDepth, index.Secid, stats.Beta)
+	printRegression(printer, stats, spec)
+	printRegimeBetas(printer, regimes)
+
+	value := portfolioValue(command, returns)
+	if value != 0 {
+		exposure := (command.TargetBeta - stats.Beta) * value
+		printer.Printf("To reach beta %f add %.2f RUB of %s exposure\n", command.TargetBeta, exposure, index.Secid)
+	}
+	return nil
+}
+
+// ///////////////////////////////////////////////////////////////////
+// Calculate beta of the portfolio on the future and the number of
+// contracts needed to reach the target beta
+// ///////////////////////////////////////////////////////////////////
+func (calculator *hedgeCalculator) executePortfolio(command Command, resolver *assetResolver, spec returnSpec,
+	cleaning cleaningSpec, levels []float64) error {
+	portfolio, err := LoadPortfolio(command.Portfolio)
+	if err != nil {
+		return err
+	}
+
+	hedge, err := resolver.GetAsset(command.Hedge)
+	if err != nil {
+		return err
+	}
+
+	returns, err := getPortfolioReturns(resolver, portfolio, hedge, command.HistoryDepth, spec)
+	if err != nil {
+		return err
+	}
+	stats, regimes := portfolioRegression(command, cleaning, hedge.Secid, returns)
+	if err = reportPortfolio(command, hedge.Secid, stats, regimes); err != nil {
+		return err
+	}
+
+	printer, err := GetPrinter()
+	if err != nil {
+		return err
+	}
+	printPortfolio(printer, portfolio, returns)
+	printer.Printf("Hedge is estimated on %s taken with %s\n", spec, describeWeighting(decayFactor(command)))
+	printer.Printf("Beta of the portfolio on %s is %f, hedging efficiency is %f\n", hedge.Secid, stats.Beta, stats.RSquared)
+
+	value := portfolioValue(command, returns)
+	if value == 0 {
+		printer.Printf("Portfolio is given by weights, specify its value by -size and -units rub to size the hedge\n")
+		return nil
+	}
+
+	info, err := resolver.GetFutureInfo(hedge.Secid)
+	if err != nil {
+		return fmt.Errorf("failed to get specification of %s, hedge must be a future to size it in contracts: %s", hedge.Secid, err)
+	}
+	price := futurePrice(returns.indexHistory, info)
+	contract, err := contractValue(price, info)
+	if err != nil {
+		return err
+	}
+
+	contracts := sizeHedge(stats.Beta-command.TargetBeta, value, info, contract)
+	printContractHedge(printer, hedge.Secid, contracts)
+	printer.Printf("Resulting beta of the portfolio is %f (target %f)\n",
+		stats.Beta+contracts.hedgedValue/value, command.TargetBeta)
+	daily := marginReturns(hedge.Secid, returns.indexHistory)
+	margin, err := estimateMargin(info, contracts.contracts, price, daily, ewmaWeights(len(daily), decayFactor(command)), levels)
+	if err != nil {
+		return err
+	}
+	printMarginEstimate(printer, hedge.Secid, margin)
+	return nil
+}
+
+// ///////////////////////////////////////////////////////////////////
+// Write beta of the portfolio to the report if it is requested. The
+// portfolio is reported under the name of its file
+// ///////////////////////////////////////////////////////////////////
+func reportPortfolio(command Command, index string, stats Regression, regimes RegimeBetas) error {
+	if len(command.Report) == 0 {
+		return nil
+	}
+	report, err := NewReport(command.Report)
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %s", err)
+	}
+	defer report.Close()
+
+	err = report.AddReport(filepath.Base(command.Portfolio), index, stats, regimes, time.Now().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return fmt.Errorf("failed to add line to report: %s", err)
+	}
+	return nil
+}
+
+// ///////////////////////////////////////////////////////////////////
+// Regress cleaned returns of the portfolio on returns of the index
+// ///////////////////////////////////////////////////////////////////
+func portfolioRegression(command Command, cleaning cleaningSpec, index string, returns portfolioReturns) (Regression, RegimeBetas) {
+	dates, portfolio, indexReturns, cleaned := cleanReturns(cleaning, returns.dates, "portfolio", returns.returns, index, returns.indexReturns)
+	for _, summary := range cleaned {
+		fmt.Println(describeCleaning(cleaning, summary))
+	}
+
+	weights := ewmaWeights(len(dates), decayFactor(command))
+	threshold, _ := parseThreshold(command.Threshold, indexReturns, weights)
+	return weightedRegression(indexReturns, portfolio, weights), regimeRegression(indexReturns, portfolio, weights, threshold)
+}
+
+// ///////////////////////////////////////////////////////////////////
+// Get RUB value of the portfolio: sum of positions or the size given
+// on the command line for portfolio given by weights
+// ///////////////////////////////////////////////////////////////////
+func portfolioValue(command Command, returns portfolioReturns) float64 {
+	if returns.value != 0 {
+		return returns.value
+	}
+	if command.PositionUnits == notionalPosition {
+		return command.PositionSize
+	}
+	return 0
+}
@@ -0,0 +1,101 @@
+package hedging
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writePortfolio(t *testing.T, name string, content string) string {
+	filename := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(filename, []byte(content), 0o644))
+	return filename
+}
+
+func TestLoadPortfolioCSV(t *testing.T) {
+	filename := writePortfolio(t, "portfolio.csv", "ticker,quantity\nSBER,100\nGAZP, 250.5\n")
+	portfolio, err := LoadPortfolio(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, []Position{{Ticker: "SBER", Quantity: 100}, {Ticker: "GAZP", Quantity: 250.5}}, portfolio.Positions)
+	assert.False(t, portfolio.byWeights())
+}
+
+func TestLoadPortfolioYAML(t *testing.T) {
+	filename := writePortfolio(t, "portfolio.yaml", `
+positions:
+  - ticker: SBER
+    weight: 0.6
+  - ticker: LKOH
+    weight: 0.4
+`)
+	portfolio, err := LoadPortfolio(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, []Position{{Ticker: "SBER", Weight: 0.6}, {Ticker: "LKOH", Weight: 0.4}}, portfolio.Positions)
+	assert.True(t, portfolio.byWeights())
+}
+
+func TestLoadInvalidPortfolio(t *testing.T) {
+	for name, content := range map[string]string{
+		"empty.csv":    "ticker,quantity\n",
+		"noticker.csv": "secid,quantity\nSBER,100\n",
+		"mixed.csv":    "ticker,quantity,weight\nSBER,100,\nGAZP,,0.5\n",
+		"both.csv":     "ticker,quantity,weight\nSBER,100,0.5\n",
+		"wrong.csv":    "ticker,quantity\nSBER,many\n",
+		"list.txt":     "SBER 100\n",
+	} {
+		_, err := LoadPortfolio(writePortfolio(t, name, content))
+		assert.Error(t, err, name)
+	}
+
+	_, err := LoadPortfolio(filepath.Join(t.TempDir(), "missing.csv"))
+	assert.Error(t, err)
+}
+
+func TestPortfolioValue(t *testing.T) {
+	assert.Equal(t, 1000.0, portfolioValue(Command{}, portfolioReturns{value: 1000}))
+	assert.Equal(t, 500.0, portfolioValue(Command{PositionSize: 500, PositionUnits: "rub"}, portfolioReturns{}))
+	assert.Equal(t, 0.0, portfolioValue(Command{PositionSize: 500, PositionUnits: "shares"}, portfolioReturns{}))
+}
+
+func TestPortfolioRegression(t *testing.T) {
+	index := []float64{0.01, -0.02, 0.015, 0.005, -0.01, 0.02}
+	portfolio := make([]float64, len(index))
+	for idx := range index {
+		portfolio[idx] = 0.8 * index[idx]
+	}
+	returns := portfolioReturns{dates: make([]string, len(index)), returns: portfolio, indexReturns: index}
+
+	stats, regimes := portfolioRegression(Command{}, cleaningSpec{method: noOutliers}, "IMOEX", returns)
+	assert.InDelta(t, 0.8, stats.Beta, 1e-9)
+	assert.InDelta(t, 0.8, regimes.Downside.Beta, 1e-9)
+}
+
+func TestReportPortfolio(t *testing.T) {
+	assert.NoError(t, reportPortfolio(Command{Portfolio: "portfolio.csv"}, "IMOEX", Regression{Beta: 0.9}, RegimeBetas{}))
+
+	filename := filepath.Join(t.TempDir(), "report.db")
+	command := Command{Report: filename, Portfolio: filepath.Join("positions", "portfolio.csv")}
+	assert.NoError(t, reportPortfolio(command, "IMOEX", Regression{Beta: 0.9, Observations: 250}, RegimeBetas{}))
+
+	db, err := sql.Open("sqlite3", filename)
+	assert.NoError(t, err)
+	defer db.Close()
+	var beta float64
+	assert.NoError(t, db.QueryRow("SELECT beta FROM report WHERE ticker = ? AND index_name = ?", "portfolio.csv", "IMOEX").Scan(&beta))
+	assert.Equal(t, 0.9, beta)
+}
+
+func TestPortfolioRejectsPairModels(t *testing.T) {
+	command := Command{Hedge: "IMOEX", Portfolio: "portfolio.csv", HistoryDepth: 6, Kalman: true}
+	err := (&hedgeCalculator{}).Execute(command)
+	assert.EqualError(t, err, "-kalman is not supported for portfolio hedge")
+
+	command = Command{Hedge: "IMOEX", Portfolio: "portfolio.csv", HistoryDepth: 6, RollingWindow: 60, RollingStep: 1}
+	err = (&betaCalculator{}).Execute(command)
+	assert.EqualError(t, err, "-window is not supported for portfolio beta")
+	err = (&betaCalculator{}).Execute(Command{Hedge: "IMOEX", Portfolio: "portfolio.csv", HistoryDepth: 6, Lags: 2})
+	assert.EqualError(t, err, "-lags is not supported for portfolio beta")
+}
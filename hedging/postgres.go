@@ -0,0 +1,88 @@
+package hedging
+
+// PostgreSQL cache schema history, append new steps to the end.
+// Dates are kept as YYYY-MM-DD text to be compared the same way as in SQLite
+var postgresCacheMigrations = []migration{
+	{
+		version: 1,
+		statements: []string{`
+			CREATE TABLE IF NOT EXISTS profits (
+				ticker TEXT NOT NULL,
+				date TEXT NOT NULL,
+				profit DOUBLE PRECISION,
+				PRIMARY KEY (ticker, date)
+			)`,
+		},
+	},
+	{
+		version: 2,
+		statements: []string{`
+			CREATE TABLE IF NOT EXISTS metadata (
+				kind TEXT NOT NULL,
+				key TEXT NOT NULL,
+				value TEXT NOT NULL,
+				fetched BIGINT NOT NULL,
+				PRIMARY KEY (kind, key)
+			)`,
+		},
+	},
+	{
+		// Profits were open-to-close before, they are close-to-close now
+		version:    3,
+		statements: []string{"DELETE FROM profits"},
+	},
+}
+
+// PostgreSQL report schema history, append new steps to the end
+var postgresReportMigrations = []migration{
+	{
+		version: 1,
+		statements: []string{`
+			CREATE TABLE IF NOT EXISTS report (
+				ticker TEXT NOT NULL,
+				index_name TEXT NOT NULL,
+				beta DOUBLE PRECISION NOT NULL,
+				date TEXT NOT NULL,
+				PRIMARY KEY (ticker, index_name)
+			)`,
+		},
+	},
+	{
+		version: 2,
+		statements: []string{
+			"ALTER TABLE report ADD COLUMN alpha DOUBLE PRECISION",
+			"ALTER TABLE report ADD COLUMN r_squared DOUBLE PRECISION",
+			"ALTER TABLE report ADD COLUMN residual_volatility DOUBLE PRECISION",
+			"ALTER TABLE report ADD COLUMN alpha_stderr DOUBLE PRECISION",
+			"ALTER TABLE report ADD COLUMN beta_stderr DOUBLE PRECISION",
+			"ALTER TABLE report ADD COLUMN alpha_tstat DOUBLE PRECISION",
+			"ALTER TABLE report ADD COLUMN beta_tstat DOUBLE PRECISION",
+			"ALTER TABLE report ADD COLUMN alpha_pvalue DOUBLE PRECISION",
+			"ALTER TABLE report ADD COLUMN beta_pvalue DOUBLE PRECISION",
+			"ALTER TABLE report ADD COLUMN observations INTEGER",
+		},
+	},
+	{
+		version: 3,
+		statements: []string{`
+			CREATE TABLE IF NOT EXISTS rolling_beta (
+				ticker TEXT NOT NULL,
+				index_name TEXT NOT NULL,
+				window_size INTEGER NOT NULL,
+				date TEXT NOT NULL,
+				beta DOUBLE PRECISION NOT NULL,
+				PRIMARY KEY (ticker, index_name, window_size, date)
+			)`,
+		},
+	},
+	{
+		version: 4,
+		statements: []string{
+			"ALTER TABLE report ADD COLUMN regime_threshold DOUBLE PRECISION",
+			"ALTER TABLE report ADD COLUMN downside_beta DOUBLE PRECISION",
+			"ALTER TABLE report ADD COLUMN downside_observations INTEGER",
+			"ALTER TABLE report ADD COLUMN upside_beta DOUBLE PRECISION",
+			"ALTER TABLE report ADD COLUMN upside_observations INTEGER",
+		},
+	},
+}
@@ -0,0 +1,69 @@
+package hedging
+
+import (
+	"fmt"
+	"strconv"
+
+	"golang.org/x/text/message"
+	"gonum.org/v1/gonum/stat"
+)
+
+// Threshold of index returns splitting the regimes by default
+const meanThreshold = "mean"
+
+// Betas estimated separately on falling and rising market
+type RegimeBetas struct {
+	Threshold float64    // index return splitting the regimes
+	Downside  Regression // on index returns below the threshold
+	Upside    Regression // on index returns at or above the threshold
+}
+
+// ////////////////////////////////////////////////////////
+// Parse threshold of index returns: either a number or
+// "mean" for the mean of index returns
+// ////////////////////////////////////////////////////////
+func parseThreshold(value string, x []float64, weights []float64) (float64, error) {
+	if value == "" || value == meanThreshold {
+		return stat.Mean(x, weights), nil
+	}
+	threshold, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("threshold must be a number or %q, got %q", meanThreshold, value)
+	}
+	return threshold, nil
+}
+
+// ////////////////////////////////////////////////////////
+// Regress y on x separately for x below the threshold and
+// for x at or above it
+// ////////////////////////////////////////////////////////
+func regimeRegression(x []float64, y []float64, weights []float64, threshold float64) RegimeBetas {
+	var downX, downY, downWeights, upX, upY, upWeights []float64
+	for idx := range x {
+		if x[idx] < threshold {
+			downX, downY = append(downX, x[idx]), append(downY, y[idx])
+			if weights != nil {
+				downWeights = append(downWeights, weights[idx])
+			}
+		} else {
+			upX, upY = append(upX, x[idx]), append(upY, y[idx])
+			if weights != nil {
+				upWeights = append(upWeights, weights[idx])
+			}
+		}
+	}
+
+	return RegimeBetas{
+		Threshold: threshold,
+		Downside:  weightedRegression(downX, downY, downWeights),
+		Upside:    weightedRegression(upX, upY, upWeights),
+	}
+}
+
+// ////////////////////////////////////////////////////////
+// Print downside and upside betas
+// ////////////////////////////////////////////////////////
+func printRegimeBetas(printer *message.Printer, regimes RegimeBetas) {
+	printer.Printf("\tdownside beta %f (%d observations), upside beta %f (%d observations), index return threshold %f\n",
+		regimes.Downside.Beta, regimes.Downside.Observations, regimes.Upside.Beta, regimes.Upside.Observations, regimes.Threshold)
+}
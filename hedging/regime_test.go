@@ -0,0 +1,46 @@
+package hedging
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseThreshold(t *testing.T) {
+	x := []float64{0.01, 0.03}
+
+	threshold, err := parseThreshold("mean", x, nil)
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.02, threshold, 1e-12)
+
+	threshold, err = parseThreshold("", x, nil)
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.02, threshold, 1e-12)
+
+	threshold, err = parseThreshold("0", x, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, threshold)
+
+	_, err = parseThreshold("median", x, nil)
+	assert.Error(t, err)
+}
+
+func TestRegimeRegression(t *testing.T) {
+	// Asset falls twice as fast as the index, but rises as fast as it
+	x := []float64{-0.02, -0.01, -0.03, -0.015, 0.01, 0.02, 0.005, 0.03}
+	y := []float64{-0.04, -0.02, -0.06, -0.03, 0.01, 0.02, 0.005, 0.03}
+
+	regimes := regimeRegression(x, y, nil, 0)
+	assert.Equal(t, 0.0, regimes.Threshold)
+	assert.InDelta(t, 2.0, regimes.Downside.Beta, 1e-9)
+	assert.Equal(t, 4, regimes.Downside.Observations)
+	assert.InDelta(t, 1.0, regimes.Upside.Beta, 1e-9)
+	assert.Equal(t, 4, regimes.Upside.Observations)
+}
+
+func TestRegimeRegressionWithEmptyRegime(t *testing.T) {
+	regimes := regimeRegression([]float64{0.01, 0.02, 0.03}, []float64{0.01, 0.02, 0.03}, ewmaWeights(3, 0.9), 0)
+	assert.Equal(t, 0, regimes.Downside.Observations)
+	assert.Equal(t, 3, regimes.Upside.Observations)
+	assert.InDelta(t, 1.0, regimes.Upside.Beta, 1e-9)
+}
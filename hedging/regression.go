@@ -0,0 +1,122 @@
+package hedging
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/stat"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// Number of trading days in a year, used to annualize daily figures
+const tradingDaysPerYear = 252
+
+// Ordinary least squares regression of asset returns on index returns
+type Regression struct {
+	Alpha              float64
+	Beta               float64
+	RSquared           float64
+	ResidualVolatility float64 // standard deviation of residuals per observation
+	AlphaStdErr        float64
+	BetaStdErr         float64
+	AlphaTStat         float64
+	BetaTStat          float64
+	AlphaPValue        float64 // two-sided
+	BetaPValue         float64 // two-sided
+	Observations       int
+}
+
+// ////////////////////////////////////////////////////////
+// Regress y on x: y = alpha + beta * x + residual.
+// Statistics which need more than two observations are NaN
+// ////////////////////////////////////////////////////////
+func linearRegression(x []float64, y []float64) Regression {
+	return weightedRegression(x, y, nil)
+}
+
+// ////////////////////////////////////////////////////////
+// Weighted least squares regression of y on x. Weights are
+// rescaled to sum to the effective number of observations,
+// so that the standard errors do not depend on the scale
+// of weights and match OLS when weights are equal
+// ////////////////////////////////////////////////////////
+func weightedRegression(x []float64, y []float64, weights []float64) Regression {
+	result := Regression{Observations: len(x)}
+	result.Alpha, result.Beta = stat.LinearRegression(x, y, weights, false)
+	result.RSquared = stat.RSquared(x, y, weights, result.Alpha, result.Beta)
+
+	nan := math.NaN()
+	result.ResidualVolatility, result.AlphaStdErr, result.BetaStdErr = nan, nan, nan
+	result.AlphaTStat, result.BetaTStat, result.AlphaPValue, result.BetaPValue = nan, nan, nan, nan
+
+	n := effectiveObservations(len(x), weights)
+	if n <= 2 {
+		return result
+	}
+
+	var weightSum float64
+	for idx := range x {
+		weightSum += weightAt(weights, idx)
+	}
+
+	var residualSum, deviationSum float64
+	meanX := stat.Mean(x, weights)
+	for idx := range x {
+		weight := weightAt(weights, idx) * n / weightSum
+		residual := y[idx] - result.Alpha - result.Beta*x[idx]
+		residualSum += weight * residual * residual
+		deviationSum += weight * (x[idx] - meanX) * (x[idx] - meanX)
+	}
+	if deviationSum == 0 {
+		return result
+	}
+
+	residualVariance := residualSum / (n - 2)
+	result.ResidualVolatility = math.Sqrt(residualVariance)
+	result.BetaStdErr = math.Sqrt(residualVariance / deviationSum)
+	result.AlphaStdErr = math.Sqrt(residualVariance * (1/n + meanX*meanX/deviationSum))
+
+	studentT := distuv.StudentsT{Mu: 0, Sigma: 1, Nu: n - 2}
+	result.AlphaTStat, result.AlphaPValue = tTest(result.Alpha, result.AlphaStdErr, studentT)
+	result.BetaTStat, result.BetaPValue = tTest(result.Beta, result.BetaStdErr, studentT)
+	return result
+}
+
+// ////////////////////////////////////////////////////////
+// Kish's effective sample size of weighted observations
+// ////////////////////////////////////////////////////////
+func effectiveObservations(n int, weights []float64) float64 {
+	if weights == nil {
+		return float64(n)
+	}
+
+	var sum, squares float64
+	for _, weight := range weights {
+		sum += weight
+		squares += weight * weight
+	}
+	if squares == 0 {
+		return 0
+	}
+	return sum * sum / squares
+}
+
+// ////////////////////////////////////////////////////////
+// Weight of the observation, nil weights are all equal to 1
+// ////////////////////////////////////////////////////////
+func weightAt(weights []float64, idx int) float64 {
+	if weights == nil {
+		return 1
+	}
+	return weights[idx]
+}
+
+// ////////////////////////////////////////////////////////
+// Two-sided test of the coefficient being zero
+// ////////////////////////////////////////////////////////
+func tTest(coefficient float64, stdErr float64, distribution distuv.StudentsT) (float64, float64) {
+	if stdErr == 0 {
+		return math.NaN(), math.NaN()
+	}
+	tStat := coefficient / stdErr
+	return tStat, 2 * distribution.Survival(math.Abs(tStat))
+}
@@ -0,0 +1,52 @@
+package hedging
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinearRegression(t *testing.T) {
+	x := []float64{1, 2, 3, 4, 5}
+	y := []float64{3.1, 4.9, 7.2, 8.8, 11.0}
+
+	result := linearRegression(x, y)
+	const delta = 1e-6
+	assert.Equal(t, 5, result.Observations)
+	assert.InDelta(t, 1.97, result.Beta, delta)
+	assert.InDelta(t, 1.09, result.Alpha, delta)
+	assert.InDelta(t, 0.997660668, result.RSquared, delta)
+	assert.InDelta(t, 0.174164673, result.ResidualVolatility, delta)
+	assert.InDelta(t, 0.055075705, result.BetaStdErr, delta)
+	assert.InDelta(t, 0.182665450, result.AlphaStdErr, delta)
+	assert.InDelta(t, 35.76894718, result.BetaTStat, 1e-4)
+	assert.InDelta(t, 5.967193026, result.AlphaTStat, delta)
+	assert.InDelta(t, 4.805422e-05, result.BetaPValue, 1e-9)
+	assert.InDelta(t, 0.009416869, result.AlphaPValue, delta)
+}
+
+func TestLinearRegressionMatchesCovarianceBeta(t *testing.T) {
+	index := []float64{0.01, -0.02, 0.015, 0.003, -0.007, 0.012}
+	asset := []float64{0.02, -0.025, 0.01, 0.001, -0.012, 0.02}
+
+	result := linearRegression(index, asset)
+	var meanIndex, meanAsset float64
+	for idx := range index {
+		meanIndex += index[idx] / float64(len(index))
+		meanAsset += asset[idx] / float64(len(asset))
+	}
+	var covariance, variance float64
+	for idx := range index {
+		covariance += (index[idx] - meanIndex) * (asset[idx] - meanAsset)
+		variance += (index[idx] - meanIndex) * (index[idx] - meanIndex)
+	}
+	assert.InDelta(t, covariance/variance, result.Beta, 1e-12)
+}
+
+func TestLinearRegressionTooFewObservations(t *testing.T) {
+	result := linearRegression([]float64{1, 2}, []float64{2, 4})
+	assert.InDelta(t, 2, result.Beta, 1e-12)
+	assert.True(t, math.IsNaN(result.BetaStdErr))
+	assert.True(t, math.IsNaN(result.BetaPValue))
+}
@@ -1,38 +1,178 @@
 package hedging
 
-import "database/sql"
+import (
+	"fmt"
+	"sync"
+)
 
-type Report struct {
-	db *sql.DB
+// Storage of calculation results
+type Report interface {
+	AddReport(ticker string, index string, stats Regression, regimes RegimeBetas, date string) error
+	AddRollingBeta(ticker string, index string, window int, series []RollingBeta) error
+	Close() error
 }
 
-func NewReport(filename string) (*Report, error) {
-	db, err := sql.Open("sqlite3", filename)
+// ////////////////////////////////////////////////////////
+// Open report by DSN, see parseDSN for supported formats
+// ////////////////////////////////////////////////////////
+func NewReport(dsn string) (Report, error) {
+	backend, _ := parseDSN(dsn)
+	if backend == memoryBackend {
+		return &memoryReport{}, nil
+	}
+
+	database, err := openDatabase(dsn, reportSchema)
 	if err != nil {
 		return nil, err
 	}
+	return &sqlReport{database}, nil
+}
 
-	const createReportTable string = `
-		CREATE TABLE IF NOT EXISTS report (
-			ticker STRING NOT NULL,
-			index_name STRING NOT NULL,
-			beta REAL NOT NULL,
-			date DATETIME NOT NULL,
-			primary key (ticker, index_name)
-		)`
+// Report kept in SQLite file or PostgreSQL database
+type sqlReport struct {
+	sqlDatabase
+}
 
-	if _, err = db.Exec(createReportTable); err != nil {
-		return nil, err
+// SQLite report schema history, append new steps to the end
+var reportMigrations = []migration{
+	{
+		version: 1,
+		statements: []string{`
+			CREATE TABLE IF NOT EXISTS report (
+				ticker STRING NOT NULL,
+				index_name STRING NOT NULL,
+				beta REAL NOT NULL,
+				date DATETIME NOT NULL,
+				primary key (ticker, index_name)
+			)`,
+		},
+	},
+	{
+		version: 2,
+		statements: []string{
+			"ALTER TABLE report ADD COLUMN alpha REAL",
+			"ALTER TABLE report ADD COLUMN r_squared REAL",
+			"ALTER TABLE report ADD COLUMN residual_volatility REAL",
+			"ALTER TABLE report ADD COLUMN alpha_stderr REAL",
+			"ALTER TABLE report ADD COLUMN beta_stderr REAL",
+			"ALTER TABLE report ADD COLUMN alpha_tstat REAL",
+			"ALTER TABLE report ADD COLUMN beta_tstat REAL",
+			"ALTER TABLE report ADD COLUMN alpha_pvalue REAL",
+			"ALTER TABLE report ADD COLUMN beta_pvalue REAL",
+			"ALTER TABLE report ADD COLUMN observations INTEGER",
+		},
+	},
+	{
+		version: 3,
+		statements: []string{`
+			CREATE TABLE IF NOT EXISTS rolling_beta (
+				ticker STRING NOT NULL,
+				index_name STRING NOT NULL,
+				window_size INTEGER NOT NULL,
+				date DATETIME NOT NULL,
+				beta REAL NOT NULL,
+				primary key (ticker, index_name, window_size, date)
+			)`,
+		},
+	},
+	{
+		version: 4,
+		statements: []string{
+			"ALTER TABLE report ADD COLUMN regime_threshold REAL",
+			"ALTER TABLE report ADD COLUMN downside_beta REAL",
+			"ALTER TABLE report ADD COLUMN downside_observations INTEGER",
+			"ALTER TABLE report ADD COLUMN upside_beta REAL",
+			"ALTER TABLE report ADD COLUMN upside_observations INTEGER",
+		},
+	},
+}
+
+func (report *sqlReport) AddReport(ticker string, index string, stats Regression, regimes RegimeBetas, date string) error {
+	_, err := report.db.Exec(report.dialect.rebind(`
+		INSERT INTO report (ticker, index_name, beta, date, alpha, r_squared, residual_volatility,
+			alpha_stderr, beta_stderr, alpha_tstat, beta_tstat, alpha_pvalue, beta_pvalue, observations,
+			regime_threshold, downside_beta, downside_observations, upside_beta, upside_observations)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (ticker, index_name) DO UPDATE SET beta = excluded.beta, date = excluded.date,
+			alpha = excluded.alpha, r_squared = excluded.r_squared, residual_volatility = excluded.residual_volatility,
+			alpha_stderr = excluded.alpha_stderr, beta_stderr = excluded.beta_stderr,
+			alpha_tstat = excluded.alpha_tstat, beta_tstat = excluded.beta_tstat,
+			alpha_pvalue = excluded.alpha_pvalue, beta_pvalue = excluded.beta_pvalue,
+			observations = excluded.observations, regime_threshold = excluded.regime_threshold,
+			downside_beta = excluded.downside_beta, downside_observations = excluded.downside_observations,
+			upside_beta = excluded.upside_beta, upside_observations = excluded.upside_observations`),
+		ticker, index, stats.Beta, date, stats.Alpha, stats.RSquared, stats.ResidualVolatility,
+		stats.AlphaStdErr, stats.BetaStdErr, stats.AlphaTStat, stats.BetaTStat, stats.AlphaPValue, stats.BetaPValue,
+		stats.Observations, regimes.Threshold, regimes.Downside.Beta, regimes.Downside.Observations,
+		regimes.Upside.Beta, regimes.Upside.Observations)
+	return err
+}
+
+func (report *sqlReport) AddRollingBeta(ticker string, index string, window int, series []RollingBeta) error {
+	tx, err := report.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statement, err := tx.Prepare(report.dialect.rebind(`
+		INSERT INTO rolling_beta (ticker, index_name, window_size, date, beta) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (ticker, index_name, window_size, date) DO UPDATE SET beta = excluded.beta`))
+	if err != nil {
+		return err
 	}
+	defer statement.Close()
 
-	return &Report{db: db}, nil
+	for _, point := range series {
+		if _, err = statement.Exec(ticker, index, window, point.Date, point.Beta); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
-func (report *Report) AddReport(ticker string, index string, beta float64, date string) error {
-	_, err := report.db.Exec("INSERT OR REPLACE INTO report (ticker, index_name, beta, date) VALUES (?, ?, ?, ?)", ticker, index, beta, date)
-	return err
+// Single line of the in-memory report
+type reportLine struct {
+	ticker  string
+	index   string
+	stats   Regression
+	regimes RegimeBetas
+	date    string
+}
+
+// Report kept in memory, for tests and runs which should leave no files
+type memoryReport struct {
+	mutex   sync.Mutex
+	lines   []reportLine
+	rolling map[string][]RollingBeta // ticker/index/window -> series
+}
+
+func (report *memoryReport) AddReport(ticker string, index string, stats Regression, regimes RegimeBetas, date string) error {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+
+	line := reportLine{ticker: ticker, index: index, stats: stats, regimes: regimes, date: date}
+	for idx := range report.lines {
+		if report.lines[idx].ticker == ticker && report.lines[idx].index == index {
+			report.lines[idx] = line
+			return nil
+		}
+	}
+	report.lines = append(report.lines, line)
+	return nil
+}
+
+func (report *memoryReport) AddRollingBeta(ticker string, index string, window int, series []RollingBeta) error {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+
+	if report.rolling == nil {
+		report.rolling = make(map[string][]RollingBeta)
+	}
+	report.rolling[fmt.Sprintf("%s/%s/%d", ticker, index, window)] = series
+	return nil
 }
 
-func (report *Report) Close() error {
-	return report.db.Close()
+func (report *memoryReport) Close() error {
+	return nil
 }
@@ -2,19 +2,22 @@ package hedging
 
 import (
 	"database/sql"
+	"path/filepath"
 	"testing"
 )
 
 func TestNewReport(t *testing.T) {
 	// Test creating a new report
-	report, err := NewReport("test.db")
+	filename := filepath.Join(t.TempDir(), "test.db")
+	report, err := NewReport(filename)
 	if err != nil {
 		t.Fatalf("Failed to create report: %v", err)
 	}
 	defer report.Close()
 
 	// Test adding a report entry
-	err = report.AddReport("AAPL", "S&P 500", 1.2, "2023-10-01")
+	regimes := RegimeBetas{Downside: Regression{Beta: 1.4, Observations: 120}, Upside: Regression{Beta: 1.0, Observations: 130}}
+	err = report.AddReport("AAPL", "S&P 500", Regression{Beta: 1.2, Alpha: 0.001, Observations: 250}, regimes, "2023-10-01")
 	if err != nil {
 		t.Fatalf("Failed to add report entry: %v", err)
 	}
@@ -26,7 +29,7 @@ func TestNewReport(t *testing.T) {
 	}
 
 	// Open database to verify the entry was added
-	db, err := sql.Open("sqlite3", "test.db")
+	db, err := sql.Open("sqlite3", filename)
 	if err != nil {
 		t.Fatalf("Failed to open database: %v", err)
 	}
@@ -41,4 +44,76 @@ func TestNewReport(t *testing.T) {
 	if count != 1 {
 		t.Fatalf("Expected 1 entry in the database, got %d", count)
 	}
+
+	// Check the regression statistics are stored as well
+	var alpha float64
+	var observations int
+	err = db.QueryRow("SELECT alpha, observations FROM report WHERE ticker = ?", "AAPL").Scan(&alpha, &observations)
+	if err != nil {
+		t.Fatalf("Failed to query database: %v", err)
+	}
+	if alpha != 0.001 || observations != 250 {
+		t.Fatalf("Unexpected regression statistics: alpha %f, observations %d", alpha, observations)
+	}
+
+	// Check the downside and upside betas are stored as well
+	var downsideBeta float64
+	var downsideObservations int
+	err = db.QueryRow("SELECT downside_beta, downside_observations FROM report WHERE ticker = ?", "AAPL").Scan(&downsideBeta, &downsideObservations)
+	if err != nil {
+		t.Fatalf("Failed to query database: %v", err)
+	}
+	if downsideBeta != 1.4 || downsideObservations != 120 {
+		t.Fatalf("Unexpected downside beta: %f, observations %d", downsideBeta, downsideObservations)
+	}
+}
+
+func TestMemoryReport(t *testing.T) {
+	report, err := NewReport(MemoryDSN)
+	if err != nil {
+		t.Fatalf("Failed to create report: %v", err)
+	}
+	defer report.Close()
+
+	for _, beta := range []float64{1.2, 1.3} {
+		if err = report.AddReport("AAPL", "S&P 500", Regression{Beta: beta}, RegimeBetas{}, "2023-10-01"); err != nil {
+			t.Fatalf("Failed to add report entry: %v", err)
+		}
+	}
+
+	lines := report.(*memoryReport).lines
+	if len(lines) != 1 || lines[0].stats.Beta != 1.3 {
+		t.Fatalf("Expected single entry with the last beta, got %v", lines)
+	}
+}
+
+func TestRollingBetaReport(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "report.db")
+	report, err := NewReport(filename)
+	if err != nil {
+		t.Fatalf("Failed to create report: %v", err)
+	}
+
+	series := []RollingBeta{{Date: "2024-01-03", Beta: 1.1}, {Date: "2024-01-04", Beta: 1.2}}
+	for i := 0; i < 2; i++ {
+		if err = report.AddRollingBeta("SBER", "IMOEX", 20, series); err != nil {
+			t.Fatalf("Failed to add rolling beta: %v", err)
+		}
+	}
+	report.Close()
+
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM rolling_beta WHERE ticker = ? AND window_size = ?", "SBER", 20).Scan(&count)
+	if err != nil {
+		t.Fatalf("Failed to query database: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("Expected 2 points in the database, got %d", count)
+	}
 }
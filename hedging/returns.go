@@ -0,0 +1,278 @@
+package hedging
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+
+	"github.com/TuliMyrskyTaivas/hedging/moex"
+)
+
+// Ways to calculate return of the price
+const (
+	simpleReturns = "simple"
+	logReturns    = "log"
+)
+
+// Prices the returns are calculated on
+const (
+	closeToClose   = "close"  // close of the previous period to close of the period
+	openToClose    = "open"   // open of the period to close of the same period
+	settleToSettle = "settle" // settlement prices of futures, close prices of other assets
+)
+
+// Names of the prices in descriptions of returns
+var priceLabels = map[string]string{
+	closeToClose:   "close-to-close",
+	openToClose:    "open-to-close",
+	settleToSettle: "settle-to-settle",
+}
+
+// Sampling frequency of returns
+const (
+	dailyReturns   = "daily"
+	weeklyReturns  = "weekly"
+	monthlyReturns = "monthly"
+)
+
+// Definition of returns used by calculations
+type returnSpec struct {
+	kind      string
+	prices    string
+	frequency string
+}
+
+var defaultReturnSpec = returnSpec{kind: simpleReturns, prices: closeToClose, frequency: dailyReturns}
+
+// ////////////////////////////////////////////////////////
+// Get definition of returns from the command, empty
+// fields are taken from the default definition
+// ////////////////////////////////////////////////////////
+func newReturnSpec(command Command) (returnSpec, error) {
+	spec := returnSpec{kind: command.Returns, prices: command.Prices, frequency: command.Frequency}
+	if spec.kind == "" {
+		spec.kind = defaultReturnSpec.kind
+	}
+	if spec.prices == "" {
+		spec.prices = defaultReturnSpec.prices
+	}
+	if spec.frequency == "" {
+		spec.frequency = defaultReturnSpec.frequency
+	}
+
+	if spec.kind != simpleReturns && spec.kind != logReturns {
+		return spec, fmt.Errorf("unknown kind of returns %s, use %s or %s", spec.kind, simpleReturns, logReturns)
+	}
+	if spec.prices != closeToClose && spec.prices != openToClose && spec.prices != settleToSettle {
+		return spec, fmt.Errorf("unknown prices %s, use %s, %s or %s", spec.prices, closeToClose, openToClose, settleToSettle)
+	}
+	if spec.frequency != dailyReturns && spec.frequency != weeklyReturns && spec.frequency != monthlyReturns {
+		return spec, fmt.Errorf("unknown frequency %s, use %s, %s or %s", spec.frequency, dailyReturns, weeklyReturns, monthlyReturns)
+	}
+	return spec, nil
+}
+
+func (spec returnSpec) String() string {
+	return fmt.Sprintf("%s %s %s returns", spec.frequency, spec.kind, priceLabels[spec.prices])
+}
+
+// Number of returns in a year to annualize statistics
+func (spec returnSpec) periodsPerYear() float64 {
+	switch spec.frequency {
+	case weeklyReturns:
+		return 52
+	case monthlyReturns:
+		return 12
+	}
+	return tradingDaysPerYear
+}
+
+// Prices of the sampling period
+type priceBar struct {
+	date  string // last trading date of the period
+	open  float64
+	close float64
+}
+
+// ////////////////////////////////////////////////////////
+// Calculate returns of the history according to the spec.
+// Returns are dated by the last trading date of the period,
+// periods with missing prices are skipped
+// ////////////////////////////////////////////////////////
+func calculateReturns(history []moex.HistoryItem, spec returnSpec) ([]string, []float64) {
+	bars := resampleHistory(history, spec)
+
+	var dates []string
+	var returns []float64
+	for idx, bar := range bars {
+		var from float64
+		if spec.prices == openToClose {
+			from = bar.open
+		} else if idx > 0 {
+			from = bars[idx-1].close
+		} else {
+			continue
+		}
+
+		if from == 0 || bar.close == 0 {
+			slog.Debug(fmt.Sprintf("missing price on %s, return is skipped", bar.date))
+			continue
+		}
+		dates = append(dates, bar.date)
+		if spec.kind == logReturns {
+			returns = append(returns, math.Log(bar.close/from))
+		} else {
+			returns = append(returns, bar.close/from-1)
+		}
+	}
+	return dates, returns
+}
+
+// ////////////////////////////////////////////////////////
+// Group daily history into bars of the sampling period
+// ////////////////////////////////////////////////////////
+func resampleHistory(history []moex.HistoryItem, spec returnSpec) []priceBar {
+	var bars []priceBar
+	var lastPeriod string
+	for _, item := range history {
+		price := item.Close
+		if spec.prices == settleToSettle && item.Settleprice != 0 {
+			price = item.Settleprice
+		}
+
+		period := samplingPeriod(item.Tradedate, spec.frequency)
+		if len(bars) > 0 && period == lastPeriod {
+			bars[len(bars)-1].date = item.Tradedate
+			bars[len(bars)-1].close = price
+			continue
+		}
+		bars = append(bars, priceBar{date: item.Tradedate, open: item.Open, close: price})
+		lastPeriod = period
+	}
+	return bars
+}
+
+// ////////////////////////////////////////////////////////
+// Get the key of the sampling period the date belongs to
+// ////////////////////////////////////////////////////////
+func samplingPeriod(tradedate string, frequency string) string {
+	switch frequency {
+	case weeklyReturns:
+		year, week := moex.ParseTime(tradedate).ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case monthlyReturns:
+		return moex.ParseTime(tradedate).Format("2006-01")
+	}
+	return tradedate
+}
+
+// ////////////////////////////////////////////////////////
+// Check whether all returns are zero: MOEX reports same
+// open and close prices for some indices
+// ////////////////////////////////////////////////////////
+func returnsAreFlat(returns []float64) bool {
+	for _, item := range returns {
+		if item != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// ////////////////////////////////////////////////////////
+// Calculate returns of the history, falling back to close
+// to close returns if open to close ones are all zero
+// ////////////////////////////////////////////////////////
+func historyReturns(secid string, history []moex.HistoryItem, spec returnSpec) ([]string, []float64) {
+	dates, returns := calculateReturns(history, spec)
+	if spec.prices == openToClose && len(returns) > 0 && returnsAreFlat(returns) {
+		slog.Warn(fmt.Sprintf("MOEX reports same open and close prices for %s, using close to close returns", secid))
+		spec.prices = closeToClose
+		dates, returns = calculateReturns(history, spec)
+	}
+	return dates, returns
+}
+
+// ////////////////////////////////////////////////////////
+// Calculate returns of two assets over the same periods
+// ////////////////////////////////////////////////////////
+func pairedReturns(asset moex.Asset, assetHistory []moex.HistoryItem, index moex.Asset, indexHistory []moex.HistoryItem,
+	spec returnSpec) ([]string, []float64, []float64) {
+	assetHistory, indexHistory = alignHistories(assetHistory, indexHistory)
+	slog.Debug(fmt.Sprintf("history of %s contains %d items, history of %s contains %d items",
+		asset.Secid, len(assetHistory), index.Secid, len(indexHistory)))
+
+	assetDates, assetReturns := historyReturns(asset.Secid, assetHistory, spec)
+	indexDates, indexReturns := historyReturns(index.Secid, indexHistory, spec)
+	return alignReturns(assetDates, assetReturns, indexDates, indexReturns)
+}
+
+// ////////////////////////////////////////////////////////
+// Leave only history items on the dates present in both
+// histories, so that returns are taken over same periods
+// ////////////////////////////////////////////////////////
+func alignHistories(a []moex.HistoryItem, b []moex.HistoryItem) ([]moex.HistoryItem, []moex.HistoryItem) {
+	var alignedA, alignedB []moex.HistoryItem
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		if a[i].Tradedate == b[j].Tradedate {
+			alignedA = append(alignedA, a[i])
+			alignedB = append(alignedB, b[j])
+			i++
+			j++
+		} else if a[i].Tradedate < b[j].Tradedate {
+			i++
+		} else {
+			j++
+		}
+	}
+	return alignedA, alignedB
+}
+
+// ////////////////////////////////////////////////////////
+// Leave only returns on the dates present in all series
+// ////////////////////////////////////////////////////////
+func alignSeries(dates [][]string, values [][]float64) ([]string, [][]float64) {
+	counts := make(map[string]int)
+	for _, seriesDates := range dates {
+		for _, date := range seriesDates {
+			counts[date]++
+		}
+	}
+
+	var common []string
+	aligned := make([][]float64, len(values))
+	for idx, seriesDates := range dates {
+		for day, date := range seriesDates {
+			if counts[date] != len(dates) {
+				continue
+			}
+			if idx == 0 {
+				common = append(common, date)
+			}
+			aligned[idx] = append(aligned[idx], values[idx][day])
+		}
+	}
+	return common, aligned
+}
+
+// ////////////////////////////////////////////////////////
+// Leave only returns on the dates present in both series
+// ////////////////////////////////////////////////////////
+func alignReturns(datesA []string, a []float64, datesB []string, b []float64) ([]string, []float64, []float64) {
+	var dates []string
+	var alignedA, alignedB []float64
+	for i, j := 0, 0; i < len(datesA) && j < len(datesB); {
+		if datesA[i] == datesB[j] {
+			dates = append(dates, datesA[i])
+			alignedA = append(alignedA, a[i])
+			alignedB = append(alignedB, b[j])
+			i++
+			j++
+		} else if datesA[i] < datesB[j] {
+			i++
+		} else {
+			j++
+		}
+	}
+	return dates, alignedA, alignedB
+}
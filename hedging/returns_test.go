@@ -0,0 +1,109 @@
+package hedging
+
+import (
+	"math"
+	"testing"
+
+	"github.com/TuliMyrskyTaivas/hedging/moex"
+	"github.com/stretchr/testify/assert"
+)
+
+func testHistory() []moex.HistoryItem {
+	return []moex.HistoryItem{
+		{Tradedate: "2024-01-29", Open: 99, Close: 100, Settleprice: 101},
+		{Tradedate: "2024-01-30", Open: 100, Close: 105, Settleprice: 104},
+		{Tradedate: "2024-01-31", Open: 105, Close: 110, Settleprice: 108},
+		{Tradedate: "2024-02-01", Open: 110, Close: 99, Settleprice: 100},
+		{Tradedate: "2024-02-05", Open: 99, Close: 121, Settleprice: 120},
+	}
+}
+
+func TestNewReturnSpec(t *testing.T) {
+	spec, err := newReturnSpec(Command{})
+	assert.NoError(t, err)
+	assert.Equal(t, defaultReturnSpec, spec)
+
+	spec, err = newReturnSpec(Command{Returns: "log", Prices: "settle", Frequency: "weekly"})
+	assert.NoError(t, err)
+	assert.Equal(t, returnSpec{kind: logReturns, prices: settleToSettle, frequency: weeklyReturns}, spec)
+
+	_, err = newReturnSpec(Command{Returns: "percent"})
+	assert.Error(t, err)
+	_, err = newReturnSpec(Command{Prices: "vwap"})
+	assert.Error(t, err)
+	_, err = newReturnSpec(Command{Frequency: "hourly"})
+	assert.Error(t, err)
+}
+
+func TestReturnSpecString(t *testing.T) {
+	assert.Equal(t, "daily simple close-to-close returns", defaultReturnSpec.String())
+	assert.Equal(t, "daily simple open-to-close returns", returnSpec{kind: simpleReturns, prices: openToClose, frequency: dailyReturns}.String())
+	assert.Equal(t, "weekly log settle-to-settle returns", returnSpec{kind: logReturns, prices: settleToSettle, frequency: weeklyReturns}.String())
+}
+
+func TestPeriodsPerYear(t *testing.T) {
+	assert.Equal(t, float64(tradingDaysPerYear), defaultReturnSpec.periodsPerYear())
+	assert.Equal(t, 52.0, returnSpec{frequency: weeklyReturns}.periodsPerYear())
+	assert.Equal(t, 12.0, returnSpec{frequency: monthlyReturns}.periodsPerYear())
+}
+
+func TestCloseToCloseReturns(t *testing.T) {
+	dates, returns := calculateReturns(testHistory(), defaultReturnSpec)
+	assert.Equal(t, []string{"2024-01-30", "2024-01-31", "2024-02-01", "2024-02-05"}, dates)
+	assert.InDeltaSlice(t, []float64{0.05, 110.0/105 - 1, 99.0/110 - 1, 121.0/99 - 1}, returns, 1e-12)
+}
+
+func TestOpenToCloseLogReturns(t *testing.T) {
+	dates, returns := calculateReturns(testHistory(), returnSpec{kind: logReturns, prices: openToClose, frequency: dailyReturns})
+	assert.Len(t, dates, 5)
+	assert.InDelta(t, math.Log(100.0/99), returns[0], 1e-12)
+	assert.InDelta(t, math.Log(121.0/99), returns[4], 1e-12)
+}
+
+func TestSettleToSettleReturns(t *testing.T) {
+	_, returns := calculateReturns(testHistory(), returnSpec{kind: simpleReturns, prices: settleToSettle, frequency: dailyReturns})
+	assert.InDelta(t, 104.0/101-1, returns[0], 1e-12)
+}
+
+func TestResampledReturns(t *testing.T) {
+	// 2024-01-29..2024-02-01 is a single week, 2024-02-05 starts the next one
+	dates, returns := calculateReturns(testHistory(), returnSpec{kind: simpleReturns, prices: closeToClose, frequency: weeklyReturns})
+	assert.Equal(t, []string{"2024-02-05"}, dates)
+	assert.InDeltaSlice(t, []float64{121.0/99 - 1}, returns, 1e-12)
+
+	dates, returns = calculateReturns(testHistory(), returnSpec{kind: simpleReturns, prices: openToClose, frequency: monthlyReturns})
+	assert.Equal(t, []string{"2024-01-31", "2024-02-05"}, dates)
+	assert.InDeltaSlice(t, []float64{110.0/99 - 1, 121.0/110 - 1}, returns, 1e-12)
+}
+
+func TestReturnsSkipMissingPrices(t *testing.T) {
+	history := testHistory()
+	history[2].Close = 0
+	dates, _ := calculateReturns(history, defaultReturnSpec)
+	assert.Equal(t, []string{"2024-01-30", "2024-02-05"}, dates)
+}
+
+func TestFlatOpenToCloseReturnsFallBack(t *testing.T) {
+	history := testHistory()
+	for idx := range history {
+		history[idx].Open = history[idx].Close
+	}
+	dates, returns := historyReturns("IMOEX", history, returnSpec{kind: simpleReturns, prices: openToClose, frequency: dailyReturns})
+	assert.Len(t, dates, 4)
+	assert.InDelta(t, 0.05, returns[0], 1e-12)
+}
+
+func TestAlignHistories(t *testing.T) {
+	a := []moex.HistoryItem{{Tradedate: "2024-01-01"}, {Tradedate: "2024-01-02"}, {Tradedate: "2024-01-04"}}
+	b := []moex.HistoryItem{{Tradedate: "2024-01-02"}, {Tradedate: "2024-01-03"}, {Tradedate: "2024-01-04"}}
+	alignedA, alignedB := alignHistories(a, b)
+	assert.Equal(t, alignedA, alignedB)
+	assert.Equal(t, []moex.HistoryItem{{Tradedate: "2024-01-02"}, {Tradedate: "2024-01-04"}}, alignedA)
+}
+
+func TestAlignReturns(t *testing.T) {
+	dates, a, b := alignReturns([]string{"2024-01-02", "2024-01-03"}, []float64{1, 2}, []string{"2024-01-03"}, []float64{3})
+	assert.Equal(t, []string{"2024-01-03"}, dates)
+	assert.Equal(t, []float64{2}, a)
+	assert.Equal(t, []float64{3}, b)
+}
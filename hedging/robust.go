@@ -0,0 +1,157 @@
+package hedging
+
+import (
+	"math"
+	"sort"
+
+	"golang.org/x/text/message"
+	"gonum.org/v1/gonum/stat"
+)
+
+// Tuning constant of Huber loss giving 95% efficiency on normal data
+const huberTuning = 1.345
+
+// Iterations of reweighted least squares and their tolerance
+const (
+	maxReweightIterations = 100
+	reweightTolerance     = 1e-10
+)
+
+// Line fitted by one of the regression estimators
+type robustFit struct {
+	estimator string
+	alpha     float64
+	beta      float64
+}
+
+// ////////////////////////////////////////////////////////
+// Fit y = alpha + beta * x by OLS and robust estimators,
+// which are less sensitive to outliers
+// ////////////////////////////////////////////////////////
+func robustRegressions(x []float64, y []float64) []robustFit {
+	alpha, beta := stat.LinearRegression(x, y, nil, false)
+	fits := []robustFit{{estimator: "OLS", alpha: alpha, beta: beta}}
+
+	alpha, beta = huberRegression(x, y)
+	fits = append(fits, robustFit{estimator: "Huber", alpha: alpha, beta: beta})
+
+	alpha, beta = theilSenRegression(x, y)
+	fits = append(fits, robustFit{estimator: "Theil-Sen", alpha: alpha, beta: beta})
+
+	alpha, beta = ladRegression(x, y)
+	return append(fits, robustFit{estimator: "LAD", alpha: alpha, beta: beta})
+}
+
+// ////////////////////////////////////////////////////////
+// Huber M-estimator: squared loss for small residuals and
+// absolute loss for large ones, fitted by iteratively
+// reweighted least squares with MAD scale of residuals
+// ////////////////////////////////////////////////////////
+func huberRegression(x []float64, y []float64) (float64, float64) {
+	return reweightedRegression(x, y, func(residuals []float64) []float64 {
+		scale := medianAbsoluteDeviation(residuals) / madScale
+		weights := make([]float64, len(residuals))
+		for idx, residual := range residuals {
+			weights[idx] = 1
+			if scale > 0 && math.Abs(residual/scale) > huberTuning {
+				weights[idx] = huberTuning / math.Abs(residual/scale)
+			}
+		}
+		return weights
+	})
+}
+
+// ////////////////////////////////////////////////////////
+// Least absolute deviations, fitted by iteratively
+// reweighted least squares with weights 1/|residual|
+// ////////////////////////////////////////////////////////
+func ladRegression(x []float64, y []float64) (float64, float64) {
+	return reweightedRegression(x, y, func(residuals []float64) []float64 {
+		weights := make([]float64, len(residuals))
+		for idx, residual := range residuals {
+			weights[idx] = 1 / math.Max(math.Abs(residual), 1e-8)
+		}
+		return weights
+	})
+}
+
+// ////////////////////////////////////////////////////////
+// Start from OLS and refit with weights derived from the
+// residuals of the previous fit until the line settles
+// ////////////////////////////////////////////////////////
+func reweightedRegression(x []float64, y []float64, reweight func([]float64) []float64) (float64, float64) {
+	alpha, beta := stat.LinearRegression(x, y, nil, false)
+	residuals := make([]float64, len(x))
+	for iteration := 0; iteration < maxReweightIterations; iteration++ {
+		for idx := range x {
+			residuals[idx] = y[idx] - alpha - beta*x[idx]
+		}
+		nextAlpha, nextBeta := stat.LinearRegression(x, y, reweight(residuals), false)
+		converged := math.Abs(nextAlpha-alpha) < reweightTolerance && math.Abs(nextBeta-beta) < reweightTolerance
+		alpha, beta = nextAlpha, nextBeta
+		if converged {
+			break
+		}
+	}
+	return alpha, beta
+}
+
+// ////////////////////////////////////////////////////////
+// Theil-Sen estimator: median of slopes between all pairs
+// of points, intercept is the median of y - beta * x
+// ////////////////////////////////////////////////////////
+func theilSenRegression(x []float64, y []float64) (float64, float64) {
+	var slopes []float64
+	for i := range x {
+		for j := i + 1; j < len(x); j++ {
+			if x[i] != x[j] {
+				slopes = append(slopes, (y[j]-y[i])/(x[j]-x[i]))
+			}
+		}
+	}
+	beta := median(slopes)
+
+	intercepts := make([]float64, len(x))
+	for idx := range x {
+		intercepts[idx] = y[idx] - beta*x[idx]
+	}
+	return median(intercepts), beta
+}
+
+// ////////////////////////////////////////////////////////
+// Median of values, NaN for empty slice
+// ////////////////////////////////////////////////////////
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+// ////////////////////////////////////////////////////////
+// Median of absolute deviations from the median
+// ////////////////////////////////////////////////////////
+func medianAbsoluteDeviation(values []float64) float64 {
+	center := median(values)
+	deviations := make([]float64, len(values))
+	for idx, value := range values {
+		deviations[idx] = math.Abs(value - center)
+	}
+	return median(deviations)
+}
+
+// ////////////////////////////////////////////////////////
+// Print slopes of the estimators side by side
+// ////////////////////////////////////////////////////////
+func printRobustFits(printer *message.Printer, slope string, fits []robustFit) {
+	printer.Printf("\t%-10s %12s %12s\n", "Estimator", slope, "Alpha")
+	for _, fit := range fits {
+		printer.Printf("\t%-10s %12f %12f\n", fit.estimator, fit.beta, fit.alpha)
+	}
+}
@@ -0,0 +1,46 @@
+package hedging
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Points on the line y = 0.001 + 1.5 * x with a single crash day
+func outlierSample() ([]float64, []float64) {
+	x := []float64{0.01, -0.02, 0.015, 0.005, -0.01, 0.02, -0.005, 0.012, -0.015, 0.008}
+	y := make([]float64, len(x))
+	for idx := range x {
+		y[idx] = 0.001 + 1.5*x[idx]
+	}
+	x = append(x, -0.01)
+	y = append(y, -0.3)
+	return x, y
+}
+
+func TestRobustRegressionsResistOutliers(t *testing.T) {
+	x, y := outlierSample()
+	fits := robustRegressions(x, y)
+	assert.Len(t, fits, 4)
+	assert.Equal(t, "OLS", fits[0].estimator)
+	assert.Greater(t, math.Abs(fits[0].beta-1.5), 0.5)
+
+	for _, fit := range fits[1:] {
+		assert.InDelta(t, 1.5, fit.beta, 0.05, fit.estimator)
+		assert.InDelta(t, 0.001, fit.alpha, 0.005, fit.estimator)
+	}
+}
+
+func TestTheilSenRegression(t *testing.T) {
+	alpha, beta := theilSenRegression([]float64{1, 2, 3, 4}, []float64{3, 5, 7, 9})
+	assert.InDelta(t, 2.0, beta, 1e-12)
+	assert.InDelta(t, 1.0, alpha, 1e-12)
+}
+
+func TestMedian(t *testing.T) {
+	assert.True(t, math.IsNaN(median(nil)))
+	assert.Equal(t, 2.0, median([]float64{3, 1, 2}))
+	assert.Equal(t, 2.5, median([]float64{4, 1, 3, 2}))
+	assert.Equal(t, 1.0, medianAbsoluteDeviation([]float64{1, 2, 3, 4, 100}))
+}
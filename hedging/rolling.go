@@ -0,0 +1,83 @@
+package hedging
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+
+	"golang.org/x/text/message"
+)
+
+// Beta estimated on the window of observations ending at the date
+type RollingBeta struct {
+	Date string
+	Beta float64
+}
+
+// ////////////////////////////////////////////////////////
+// Estimate beta on the sliding window of the specified
+// number of observations, moving it by step observations
+// ////////////////////////////////////////////////////////
+func rollingRegression(dates []string, x []float64, y []float64, window int, step int) []RollingBeta {
+	var series []RollingBeta
+	for end := window; end <= len(x); end += step {
+		stats := linearRegression(x[end-window:end], y[end-window:end])
+		series = append(series, RollingBeta{Date: dates[end-1], Beta: stats.Beta})
+	}
+	return series
+}
+
+// ////////////////////////////////////////////////////////
+// Check parameters of the rolling beta
+// ////////////////////////////////////////////////////////
+func validateRollingWindow(command Command) error {
+	if command.RollingWindow == 0 {
+		return nil
+	}
+	if command.RollingWindow < 3 {
+		return fmt.Errorf("rolling window must contain at least 3 observations")
+	}
+	if command.RollingStep <= 0 {
+		return fmt.Errorf("rolling step must be positive")
+	}
+	return nil
+}
+
+// ////////////////////////////////////////////////////////
+// Print rolling beta as a table
+// ////////////////////////////////////////////////////////
+func printRollingBeta(printer *message.Printer, asset string, index string, window int, series []RollingBeta) {
+	printer.Printf("Rolling %d-day beta of %s on %s:\n", window, asset, index)
+	printer.Printf("\t%-10s %10s\n", "Date", "Beta")
+	for _, point := range series {
+		printer.Printf("\t%-10s %10f\n", point.Date, point.Beta)
+	}
+}
+
+// ////////////////////////////////////////////////////////
+// Write rolling betas of all assets to CSV file
+// ////////////////////////////////////////////////////////
+func exportRollingBeta(filename string, index string, betas []betaReport) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if err = writer.Write([]string{"ticker", "index", "date", "beta"}); err != nil {
+		return err
+	}
+	for _, beta := range betas {
+		for _, point := range beta.rolling {
+			err = writer.Write([]string{beta.asset, index, point.Date, strconv.FormatFloat(point.Beta, 'g', -1, 64)})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,71 @@
+package hedging
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRollingRegression(t *testing.T) {
+	dates := []string{"2024-01-01", "2024-01-02", "2024-01-03", "2024-01-04", "2024-01-05", "2024-01-08"}
+	x := []float64{0.01, -0.02, 0.03, 0.01, -0.01, 0.02}
+	// Beta is 1 on the first half and 2 on the second one
+	y := []float64{0.01, -0.02, 0.03, 0.02, -0.02, 0.04}
+
+	series := rollingRegression(dates, x, y, 3, 3)
+	if len(series) != 2 {
+		t.Fatalf("Expected 2 windows, got %d", len(series))
+	}
+	if series[0].Date != "2024-01-03" || math.Abs(series[0].Beta-1) > 1e-9 {
+		t.Errorf("Unexpected first window: %v", series[0])
+	}
+	if series[1].Date != "2024-01-08" || math.Abs(series[1].Beta-2) > 1e-9 {
+		t.Errorf("Unexpected second window: %v", series[1])
+	}
+
+	series = rollingRegression(dates, x, y, 3, 1)
+	if len(series) != 4 {
+		t.Errorf("Expected 4 windows with unit step, got %d", len(series))
+	}
+
+	if series = rollingRegression(dates, x, y, 10, 1); len(series) != 0 {
+		t.Errorf("Expected no windows on short history, got %d", len(series))
+	}
+}
+
+func TestValidateRollingWindow(t *testing.T) {
+	if err := validateRollingWindow(Command{}); err != nil {
+		t.Errorf("Disabled rolling beta should be valid: %v", err)
+	}
+	if err := validateRollingWindow(Command{RollingWindow: 2}); err == nil {
+		t.Error("Expected error on too small window")
+	}
+	if err := validateRollingWindow(Command{RollingWindow: 20, RollingStep: -1}); err == nil {
+		t.Error("Expected error on negative step")
+	}
+	if err := validateRollingWindow(Command{RollingWindow: 20}); err == nil {
+		t.Error("Expected error on zero step")
+	}
+	if err := validateRollingWindow(Command{RollingWindow: 20, RollingStep: 1}); err != nil {
+		t.Errorf("Unit step should be valid: %v", err)
+	}
+}
+
+func TestExportRollingBeta(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "rolling.csv")
+	betas := []betaReport{{asset: "SBER", rolling: []RollingBeta{{Date: "2024-01-03", Beta: 1.5}}}}
+	if err := exportRollingBeta(filename, "IMOEX", betas); err != nil {
+		t.Fatalf("Failed to export rolling beta: %v", err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read export: %v", err)
+	}
+	expected := "ticker,index,date,beta\nSBER,IMOEX,2024-01-03,1.5\n"
+	if strings.TrimSpace(string(data)) != strings.TrimSpace(expected) {
+		t.Errorf("Unexpected export:\n%s", data)
+	}
+}
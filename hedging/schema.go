@@ -0,0 +1,150 @@
+package hedging
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+)
+
+// Statements shared by the database connection and a transaction
+type sqlExecutor interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// Single step of the database schema upgrade
+type migration struct {
+	version    int
+	statements []string
+}
+
+// ////////////////////////////////////////////////////////
+// Get the schema version of the database (0 for a fresh
+// database or a file created before versioning was added)
+// ////////////////////////////////////////////////////////
+func getSchemaVersion(db sqlExecutor) (int, error) {
+	const createVersionTable string = `
+		CREATE TABLE IF NOT EXISTS schema_version (
+			version INTEGER NOT NULL PRIMARY KEY
+		)`
+	// Tables created before the primary key was added
+	const createVersionIndex string = "CREATE UNIQUE INDEX IF NOT EXISTS schema_version_key ON schema_version (version)"
+
+	for _, statement := range []string{createVersionTable, createVersionIndex} {
+		if _, err := db.Exec(statement); err != nil {
+			return 0, err
+		}
+	}
+
+	var version sql.NullInt64
+	err := db.QueryRow("SELECT max(version) FROM schema_version").Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// ////////////////////////////////////////////////////////
+// Get the latest schema version known to the program
+// ////////////////////////////////////////////////////////
+func latestSchemaVersion(migrations []migration) int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].version
+}
+
+// ////////////////////////////////////////////////////////
+// Begin the transaction holding the schema lock of the
+// database until it ends. SQLite files are locked by the
+// program before opening, server databases shared by many
+// clients are locked by the statement of the dialect
+// ////////////////////////////////////////////////////////
+func beginMigration(db *sql.DB, dialect sqlDialect) (*sql.Tx, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	if len(dialect.schemaLock) > 0 {
+		if _, err = tx.Exec(dialect.schemaLock); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to lock schema: %s", err)
+		}
+	}
+	return tx, nil
+}
+
+// ////////////////////////////////////////////////////////
+// Read the schema version under the schema lock
+// ////////////////////////////////////////////////////////
+func lockedSchemaVersion(db *sql.DB, dialect sqlDialect) (int, error) {
+	tx, err := beginMigration(db, dialect)
+	if err != nil {
+		return 0, err
+	}
+	version, err := getSchemaVersion(tx)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	return version, tx.Commit()
+}
+
+// ////////////////////////////////////////////////////////
+// Apply all pending migrations in order. Returns the schema
+// versions before and after the upgrade. Every step reads
+// the version again under the schema lock, so the step
+// applied by another client meanwhile is skipped
+// ////////////////////////////////////////////////////////
+func migrateSchema(db *sql.DB, dialect sqlDialect, name string, migrations []migration) (int, int, error) {
+	current, err := lockedSchemaVersion(db, dialect)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read schema version of %s: %s", name, err)
+	}
+
+	from := current
+	latest := latestSchemaVersion(migrations)
+	if current > latest {
+		return from, current, fmt.Errorf("schema version %d of %s is newer than supported version %d, please upgrade the program",
+			current, name, latest)
+	}
+
+	for _, step := range migrations {
+		if step.version <= current {
+			continue
+		}
+
+		tx, err := beginMigration(db, dialect)
+		if err != nil {
+			return from, current, err
+		}
+		version, err := getSchemaVersion(tx)
+		if err != nil {
+			tx.Rollback()
+			return from, current, fmt.Errorf("failed to read schema version of %s: %s", name, err)
+		}
+		if current = version; step.version <= current {
+			slog.Debug(fmt.Sprintf("%s was migrated to schema version %d by another client", name, current))
+			tx.Rollback()
+			continue
+		}
+
+		slog.Debug(fmt.Sprintf("migrate %s from schema version %d to %d", name, current, step.version))
+		for _, statement := range step.statements {
+			if _, err = tx.Exec(statement); err != nil {
+				tx.Rollback()
+				return from, current, fmt.Errorf("failed to migrate %s to schema version %d: %s", name, step.version, err)
+			}
+		}
+		if _, err = tx.Exec(dialect.rebind("INSERT INTO schema_version (version) VALUES (?)"), step.version); err != nil {
+			tx.Rollback()
+			return from, current, err
+		}
+		if err = tx.Commit(); err != nil {
+			return from, current, err
+		}
+		current = step.version
+	}
+
+	return from, current, nil
+}
@@ -0,0 +1,171 @@
+package hedging
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testMigrations = []migration{
+	{version: 1, statements: []string{"CREATE TABLE IF NOT EXISTS first (id INTEGER)"}},
+	{version: 2, statements: []string{"CREATE TABLE second (id INTEGER)", "ALTER TABLE first ADD COLUMN name STRING"}},
+}
+
+func openTestDatabase(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "schema.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestMigrateSchemaFromScratch(t *testing.T) {
+	db := openTestDatabase(t)
+
+	from, to, err := migrateSchema(db, sqliteDialect, "test", testMigrations)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, from)
+	assert.Equal(t, 2, to)
+
+	_, err = db.Exec("INSERT INTO first (id, name) VALUES (1, 'one')")
+	assert.NoError(t, err)
+	_, err = db.Exec("INSERT INTO second (id) VALUES (1)")
+	assert.NoError(t, err)
+}
+
+func TestMigrateSchemaIsIncremental(t *testing.T) {
+	db := openTestDatabase(t)
+
+	_, to, err := migrateSchema(db, sqliteDialect, "test", testMigrations[:1])
+	assert.NoError(t, err)
+	assert.Equal(t, 1, to)
+
+	from, to, err := migrateSchema(db, sqliteDialect, "test", testMigrations)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, from)
+	assert.Equal(t, 2, to)
+
+	// Nothing to do on the second run
+	from, to, err = migrateSchema(db, sqliteDialect, "test", testMigrations)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, from)
+	assert.Equal(t, 2, to)
+}
+
+func TestMigrateSchemaRefusesNewerVersion(t *testing.T) {
+	db := openTestDatabase(t)
+
+	_, _, err := migrateSchema(db, sqliteDialect, "test", testMigrations)
+	assert.NoError(t, err)
+
+	_, _, err = migrateSchema(db, sqliteDialect, "test", testMigrations[:1])
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "newer than supported version 1")
+}
+
+func TestMigrateSchemaRollsBackFailedStep(t *testing.T) {
+	db := openTestDatabase(t)
+
+	broken := []migration{
+		{version: 1, statements: []string{"CREATE TABLE first (id INTEGER)", "THIS IS NOT SQL"}},
+	}
+	_, _, err := migrateSchema(db, sqliteDialect, "test", broken)
+	assert.Error(t, err)
+
+	version, err := getSchemaVersion(db)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, version)
+
+	_, err = db.Exec("SELECT 1 FROM first")
+	assert.Error(t, err)
+}
+
+func TestSchemaVersionIsUnique(t *testing.T) {
+	db := openTestDatabase(t)
+
+	// Version table created before the primary key was added
+	_, err := db.Exec("CREATE TABLE schema_version (version INTEGER NOT NULL)")
+	assert.NoError(t, err)
+	_, err = db.Exec("CREATE TABLE first (id INTEGER)")
+	assert.NoError(t, err)
+	_, err = db.Exec("INSERT INTO schema_version (version) VALUES (1)")
+	assert.NoError(t, err)
+
+	from, to, err := migrateSchema(db, sqliteDialect, "test", testMigrations)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, from)
+	assert.Equal(t, 2, to)
+
+	_, err = db.Exec("INSERT INTO schema_version (version) VALUES (2)")
+	assert.Error(t, err)
+}
+
+func TestSchemaLockOfDialects(t *testing.T) {
+	assert.Empty(t, sqliteDialect.schemaLock)
+	assert.Contains(t, postgresDialect.schemaLock, "pg_advisory_xact_lock")
+}
+
+func TestNewCacheOpensLegacyFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "legacy.db")
+	db, err := sql.Open("sqlite3", filename)
+	assert.NoError(t, err)
+	_, err = db.Exec(`CREATE TABLE profits (ticker STRING NOT NULL, date DATETIME NOT NULL, profit REAL, PRIMARY KEY (ticker, date))`)
+	assert.NoError(t, err)
+	_, err = db.Exec("INSERT INTO profits (ticker, date, profit) VALUES ('SBER', '2024-01-02', 0.1)")
+	assert.NoError(t, err)
+	db.Close()
+
+	cache, err := newSQLCache(filename)
+	assert.NoError(t, err)
+	defer cache.Close()
+
+	version, err := getSchemaVersion(cache.db)
+	assert.NoError(t, err)
+	assert.Equal(t, latestSchemaVersion(cacheMigrations), version)
+
+	// Open-to-close profits of the legacy file are purged
+	var count int
+	assert.NoError(t, cache.db.QueryRow("SELECT COUNT(*) FROM profits").Scan(&count))
+	assert.Equal(t, 0, count)
+}
+
+func TestCacheMigrationKeepsCloseToCloseProfits(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "current.db")
+	cache, err := newSQLCache(filename)
+	assert.NoError(t, err)
+	assert.NoError(t, cache.addProfitsBatches([]profitsBatch{{ticker: "SBER", dates: []string{"2024-01-02"}, profits: []float64{0.1}}}))
+	assert.NoError(t, cache.Close())
+
+	// Reopening the migrated cache does not purge it again
+	cache, err = newSQLCache(filename)
+	assert.NoError(t, err)
+	defer cache.Close()
+
+	var count int
+	assert.NoError(t, cache.db.QueryRow("SELECT COUNT(*) FROM profits").Scan(&count))
+	assert.Equal(t, 1, count)
+}
+
+func TestOpenDatabaseIsExclusive(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "locked.db")
+	saved := lockTimeout
+	lockTimeout = 200 * time.Millisecond
+	defer func() { lockTimeout = saved }()
+
+	cache, err := NewCache(filename)
+	assert.NoError(t, err)
+
+	_, err = NewCache(filename)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "locked by another process")
+
+	assert.NoError(t, cache.Close())
+	cache, err = NewCache(filename)
+	assert.NoError(t, err)
+	assert.NoError(t, cache.Close())
+}
@@ -0,0 +1,210 @@
+package hedging
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Cache kept in SQLite file or PostgreSQL database
+type sqlCache struct {
+	sqlDatabase
+}
+
+// SQLite cache schema history, append new steps to the end
+var cacheMigrations = []migration{
+	{
+		version: 1,
+		statements: []string{`
+			CREATE TABLE IF NOT EXISTS profits (
+				ticker STRING NOT NULL,
+				date DATETIME NOT NULL,
+				profit REAL,
+				PRIMARY KEY (ticker, date)
+			)`,
+		},
+	},
+	{
+		version: 2,
+		statements: []string{`
+			CREATE TABLE IF NOT EXISTS metadata (
+				kind STRING NOT NULL,
+				key STRING NOT NULL,
+				value TEXT NOT NULL,
+				fetched INTEGER NOT NULL,
+				PRIMARY KEY (kind, key)
+			)`,
+		},
+	},
+	{
+		// Profits were open-to-close before, they are close-to-close now
+		version:    3,
+		statements: []string{"DELETE FROM profits"},
+	},
+}
+
+func newSQLCache(dsn string) (*sqlCache, error) {
+	database, err := openDatabase(dsn, cacheSchema)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlCache{database}, nil
+}
+
+func (cache *sqlCache) GetAvailableRange(asset string) (time.Time, time.Time, error) {
+	result := cache.db.QueryRow(cache.dialect.rebind("SELECT min(date), max(date) FROM profits WHERE ticker=?"), asset)
+
+	var from, till string
+	err := result.Scan(&from, &till)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	const TimeFormat = "2006-01-02"
+	fromTime, err := time.Parse(TimeFormat, from)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	tillTime, err := time.Parse(TimeFormat, till)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	return fromTime, tillTime, nil
+}
+
+func (cache *sqlCache) AddProfits(ticker string, dates []string, profits []float64) error {
+	slog.Debug(fmt.Sprintf("insert %d profit records for %s", len(dates), ticker))
+	if len(dates) != len(profits) {
+		panic("the sizes of the sequences of dates and profits do not match")
+	}
+
+	return cache.addProfitsBatches([]profitsBatch{{ticker: ticker, dates: dates, profits: profits}})
+}
+
+// ////////////////////////////////////////////////////////
+// Write profits of several tickers in one transaction
+// ////////////////////////////////////////////////////////
+func (cache *sqlCache) addProfitsBatches(batches []profitsBatch) error {
+	tx, err := cache.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statement, err := tx.Prepare(cache.dialect.rebind(`
+		INSERT INTO profits (ticker, date, profit) VALUES (?, ?, ?)
+		ON CONFLICT (ticker, date) DO UPDATE SET profit = excluded.profit`))
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	count := 0
+	for _, batch := range batches {
+		for idx, date := range batch.dates {
+			if _, err = statement.Exec(batch.ticker, date, batch.profits[idx]); err != nil {
+				return fmt.Errorf("failed to insert profit of %s on %s: %s", batch.ticker, date, err)
+			}
+		}
+		count += len(batch.dates)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	slog.Debug(fmt.Sprintf("%d profit records of %d tickers written to cache", count, len(batches)))
+	return nil
+}
+
+func (cache *sqlCache) GetTickers() ([]string, error) {
+	rows, err := cache.db.Query("SELECT DISTINCT ticker FROM profits ORDER BY ticker")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tickers []string
+	for rows.Next() {
+		var ticker string
+		if err = rows.Scan(&ticker); err != nil {
+			return nil, err
+		}
+		tickers = append(tickers, ticker)
+	}
+	return tickers, rows.Err()
+}
+
+func (cache *sqlCache) GetProfits(ticker string) ([]string, []float64, error) {
+	query := fmt.Sprintf("SELECT %s, profit FROM profits WHERE ticker=? ORDER BY date", cache.dialect.dateColumn)
+	rows, err := cache.db.Query(cache.dialect.rebind(query), ticker)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var dates []string
+	var profits []float64
+	for rows.Next() {
+		var date string
+		var profit float64
+		if err = rows.Scan(&date, &profit); err != nil {
+			return nil, nil, err
+		}
+		dates = append(dates, date)
+		profits = append(profits, profit)
+	}
+	return dates, profits, rows.Err()
+}
+
+func (cache *sqlCache) CountProfits(ticker string) (int, error) {
+	var count int
+	err := cache.db.QueryRow(cache.dialect.rebind("SELECT COUNT(date) FROM profits WHERE ticker=?"), ticker).Scan(&count)
+	return count, err
+}
+
+func (cache *sqlCache) PurgeTicker(ticker string) (int64, error) {
+	result, err := cache.db.Exec(cache.dialect.rebind("DELETE FROM profits WHERE ticker=?"), ticker)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (cache *sqlCache) PurgeOlderThan(date time.Time) (int64, error) {
+	result, err := cache.db.Exec(cache.dialect.rebind("DELETE FROM profits WHERE date < ?"), date.Format("2006-01-02"))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (cache *sqlCache) GetMetadata(kind string, key string) ([]byte, time.Time, error) {
+	result := cache.db.QueryRow(cache.dialect.rebind("SELECT value, fetched FROM metadata WHERE kind=? AND key=?"), kind, key)
+
+	var value string
+	var fetched int64
+	err := result.Scan(&value, &fetched)
+	if err == sql.ErrNoRows {
+		return nil, time.Time{}, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return []byte(value), time.Unix(fetched, 0), nil
+}
+
+func (cache *sqlCache) PutMetadata(kind string, key string, value []byte) error {
+	_, err := cache.db.Exec(cache.dialect.rebind(`
+		INSERT INTO metadata (kind, key, value, fetched) VALUES (?, ?, ?, ?)
+		ON CONFLICT (kind, key) DO UPDATE SET value = excluded.value, fetched = excluded.fetched`),
+		kind, key, string(value), time.Now().Unix())
+	return err
+}
+
+func (cache *sqlCache) Vacuum() error {
+	_, err := cache.db.Exec("VACUUM")
+	return err
+}
@@ -0,0 +1,225 @@
+package hedging
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofrs/flock"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// How long to wait for another process to release the database
+var lockTimeout = 30 * time.Second
+
+// How long SQLite waits for a locked table before giving up
+const busyTimeout = 5 * time.Second
+
+// DSN of the in-memory storage, data is lost when the program exits
+const MemoryDSN = "memory:"
+
+// Kind of storage selected by DSN
+const (
+	sqliteBackend   = "sqlite"
+	postgresBackend = "postgres"
+	memoryBackend   = "memory"
+)
+
+// Kind of data kept in the database
+type schemaKind int
+
+const (
+	cacheSchema schemaKind = iota
+	reportSchema
+)
+
+// Key of PostgreSQL advisory lock serializing schema migrations of all clients
+const schemaLockKey = 0x6865646765
+
+// Differences between SQL databases supported as storage
+type sqlDialect struct {
+	driver           string
+	dateColumn       string // expression to read dates as YYYY-MM-DD
+	schemaLock       string // statement locking the schema until the end of transaction
+	cacheMigrations  []migration
+	reportMigrations []migration
+}
+
+var sqliteDialect = sqlDialect{
+	driver:           "sqlite3",
+	dateColumn:       "date(date)",
+	cacheMigrations:  cacheMigrations,
+	reportMigrations: reportMigrations,
+}
+
+var postgresDialect = sqlDialect{
+	driver:           "postgres",
+	dateColumn:       "date",
+	schemaLock:       fmt.Sprintf("SELECT pg_advisory_xact_lock(%d)", schemaLockKey),
+	cacheMigrations:  postgresCacheMigrations,
+	reportMigrations: postgresReportMigrations,
+}
+
+// ////////////////////////////////////////////////////////
+// Get schema history of the database of the specified kind
+// ////////////////////////////////////////////////////////
+func (dialect sqlDialect) migrations(schema schemaKind) []migration {
+	if schema == reportSchema {
+		return dialect.reportMigrations
+	}
+	return dialect.cacheMigrations
+}
+
+// ////////////////////////////////////////////////////////
+// Convert ? placeholders to the style of the database
+// ////////////////////////////////////////////////////////
+func (dialect sqlDialect) rebind(query string) string {
+	if dialect.driver != "postgres" {
+		return query
+	}
+
+	var builder strings.Builder
+	count := 0
+	for _, char := range query {
+		if char == '?' {
+			count++
+			builder.WriteString(fmt.Sprintf("$%d", count))
+		} else {
+			builder.WriteRune(char)
+		}
+	}
+	return builder.String()
+}
+
+// ////////////////////////////////////////////////////////
+// Detect the storage backend by DSN:
+//
+//	memory:                      - in-memory storage
+//	postgres://user@host/db      - PostgreSQL database
+//	sqlite://path or plain path  - SQLite database file
+//
+// ////////////////////////////////////////////////////////
+func parseDSN(dsn string) (string, string) {
+	switch {
+	case dsn == MemoryDSN || dsn == ":memory:":
+		return memoryBackend, ""
+	case strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://"):
+		return postgresBackend, dsn
+	case strings.HasPrefix(dsn, "sqlite://"):
+		return sqliteBackend, strings.TrimPrefix(dsn, "sqlite://")
+	}
+	return sqliteBackend, dsn
+}
+
+// ////////////////////////////////////////////////////////
+// Hide password in DSN before printing it
+// ////////////////////////////////////////////////////////
+func redactDSN(dsn string) string {
+	parsed, err := url.Parse(dsn)
+	if err != nil || parsed.User == nil {
+		return dsn
+	}
+	return parsed.Redacted()
+}
+
+// ////////////////////////////////////////////////////////
+// Open SQLite database in WAL mode, so that readers do not
+// block the writer, and wait for locks instead of failing
+// with "database is locked"
+// ////////////////////////////////////////////////////////
+func openSQLite(filename string) (*sql.DB, error) {
+	dsn := fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=%d", filename, busyTimeout.Milliseconds())
+	return sql.Open("sqlite3", dsn)
+}
+
+// ////////////////////////////////////////////////////////
+// Lock the database file so that concurrent runs wait for
+// each other instead of corrupting the data
+// ////////////////////////////////////////////////////////
+func lockDatabase(filename string) (*flock.Flock, error) {
+	lock := flock.New(filename + ".lock")
+	locked, err := lock.TryLock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock %s: %s", filename, err)
+	}
+
+	if !locked {
+		slog.Info(fmt.Sprintf("%s is used by another process, waiting...", filename))
+		ctx, cancel := context.WithTimeout(context.Background(), lockTimeout)
+		defer cancel()
+
+		locked, err = lock.TryLockContext(ctx, 100*time.Millisecond)
+		if !locked {
+			return nil, fmt.Errorf("%s is locked by another process", filename)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock %s: %s", filename, err)
+		}
+	}
+	return lock, nil
+}
+
+// SQL database opened as a storage
+type sqlDatabase struct {
+	db      *sql.DB
+	dialect sqlDialect
+	lock    *flock.Flock // nil for server databases
+}
+
+// ////////////////////////////////////////////////////////
+// Open SQL database by DSN without touching its schema.
+// SQLite files are locked until the database is closed
+// ////////////////////////////////////////////////////////
+func openSQLDatabase(dsn string) (sqlDatabase, error) {
+	backend, location := parseDSN(dsn)
+	switch backend {
+	case postgresBackend:
+		db, err := sql.Open("postgres", location)
+		if err != nil {
+			return sqlDatabase{}, err
+		}
+		return sqlDatabase{db: db, dialect: postgresDialect}, nil
+
+	case sqliteBackend:
+		lock, err := lockDatabase(location)
+		if err != nil {
+			return sqlDatabase{}, err
+		}
+		db, err := openSQLite(location)
+		if err != nil {
+			lock.Unlock()
+			return sqlDatabase{}, err
+		}
+		return sqlDatabase{db: db, dialect: sqliteDialect, lock: lock}, nil
+	}
+	return sqlDatabase{}, fmt.Errorf("%s is not an SQL database", redactDSN(dsn))
+}
+
+// ////////////////////////////////////////////////////////
+// Open SQL database and bring its schema up to date
+// ////////////////////////////////////////////////////////
+func openDatabase(dsn string, schema schemaKind) (sqlDatabase, error) {
+	database, err := openSQLDatabase(dsn)
+	if err != nil {
+		return database, err
+	}
+
+	_, _, err = migrateSchema(database.db, database.dialect, redactDSN(dsn), database.dialect.migrations(schema))
+	if err != nil {
+		database.Close()
+		return sqlDatabase{}, err
+	}
+	return database, nil
+}
+
+func (database sqlDatabase) Close() error {
+	if database.lock != nil {
+		defer database.lock.Unlock()
+	}
+	return database.db.Close()
+}
@@ -0,0 +1,98 @@
+package hedging
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// Profits of a single ticker waiting to be written to the cache
+type profitsBatch struct {
+	ticker  string
+	dates   []string
+	profits []float64
+}
+
+// Single writer of the cache: calculators running in parallel queue
+// their profits here instead of competing for the SQLite write lock
+type CacheWriter struct {
+	cache  Cache
+	queue  chan profitsBatch
+	done   chan error
+	mutex  sync.RWMutex
+	closed bool
+	result error
+}
+
+// ////////////////////////////////////////////////////////
+// Start the writer goroutine
+// ////////////////////////////////////////////////////////
+func NewCacheWriter(cache Cache) *CacheWriter {
+	writer := &CacheWriter{
+		cache: cache,
+		queue: make(chan profitsBatch, 64),
+		done:  make(chan error, 1),
+	}
+	go writer.run()
+	return writer
+}
+
+// ////////////////////////////////////////////////////////
+// Queue profits for writing. Safe for concurrent use, the
+// data written after Close is dropped
+// ////////////////////////////////////////////////////////
+func (writer *CacheWriter) Write(ticker string, dates []string, profits []float64) {
+	if len(dates) != len(profits) {
+		panic("the sizes of the sequences of dates and profits do not match")
+	}
+
+	writer.mutex.RLock()
+	defer writer.mutex.RUnlock()
+	if writer.closed {
+		slog.Debug(fmt.Sprintf("cache writer is closed, profits of %s are dropped", ticker))
+		return
+	}
+	writer.queue <- profitsBatch{ticker: ticker, dates: dates, profits: profits}
+}
+
+// ////////////////////////////////////////////////////////
+// Flush the queue and stop the writer. Returns all errors
+// occurred while writing
+// ////////////////////////////////////////////////////////
+func (writer *CacheWriter) Close() error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+	if !writer.closed {
+		writer.closed = true
+		close(writer.queue)
+		writer.result = <-writer.done
+	}
+	return writer.result
+}
+
+func (writer *CacheWriter) run() {
+	var errs []error
+	for batch := range writer.queue {
+		// Take everything queued so far and write it in one transaction
+		batches := []profitsBatch{batch}
+	drain:
+		for {
+			select {
+			case next, ok := <-writer.queue:
+				if !ok {
+					break drain
+				}
+				batches = append(batches, next)
+			default:
+				break drain
+			}
+		}
+
+		if err := writer.cache.addProfitsBatches(batches); err != nil {
+			slog.Error(fmt.Sprintf("failed to write %d batches to cache: %s", len(batches), err))
+			errs = append(errs, err)
+		}
+	}
+	writer.done <- errors.Join(errs...)
+}
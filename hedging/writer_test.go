@@ -0,0 +1,101 @@
+package hedging
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/TuliMyrskyTaivas/hedging/moex"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheWriterConcurrentWrites(t *testing.T) {
+	cache, err := NewCache(filepath.Join(t.TempDir(), "cache.db"))
+	assert.NoError(t, err)
+	defer cache.Close()
+
+	writer := NewCacheWriter(cache)
+	dates := []string{"2024-01-02", "2024-01-03", "2024-01-04"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(ticker string) {
+			defer wg.Done()
+			writer.Write(ticker, dates, []float64{0.1, 0.2, 0.3})
+			// Same data again must not fail on the primary key
+			writer.Write(ticker, dates, []float64{0.1, 0.2, 0.3})
+		}(fmt.Sprintf("T%02d", i))
+	}
+	wg.Wait()
+
+	assert.NoError(t, writer.Close())
+	assert.NoError(t, writer.Close())
+
+	// Writes after Close are dropped silently
+	writer.Write("LATE", dates, []float64{0.1, 0.2, 0.3})
+
+	tickers, err := cache.GetTickers()
+	assert.NoError(t, err)
+	assert.Len(t, tickers, 20)
+
+	count, err := cache.CountProfits("T07")
+	assert.NoError(t, err)
+	assert.Equal(t, len(dates), count)
+}
+
+func TestCacheWriterReportsErrors(t *testing.T) {
+	cache, err := newSQLCache(filepath.Join(t.TempDir(), "cache.db"))
+	assert.NoError(t, err)
+	defer cache.Close()
+
+	_, err = cache.db.Exec("DROP TABLE profits")
+	assert.NoError(t, err)
+
+	writer := NewCacheWriter(cache)
+	writer.Write("SBER", []string{"2024-01-02"}, []float64{0.1})
+	err = writer.Close()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no such table")
+}
+
+func TestCacheUsesWAL(t *testing.T) {
+	cache, err := newSQLCache(filepath.Join(t.TempDir(), "cache.db"))
+	assert.NoError(t, err)
+	defer cache.Close()
+
+	var mode string
+	assert.NoError(t, cache.db.QueryRow("PRAGMA journal_mode").Scan(&mode))
+	assert.Equal(t, "wal", mode)
+}
+
+func TestSaveProfitsOfOwnHistory(t *testing.T) {
+	cache := newMemoryCache()
+	writer := NewCacheWriter(cache)
+
+	index := []moex.HistoryItem{
+		{Tradedate: "2024-01-01", Close: 100},
+		{Tradedate: "2024-01-02", Close: 110},
+		{Tradedate: "2024-01-03", Close: 121},
+	}
+	asset := []moex.HistoryItem{
+		{Tradedate: "2024-01-01", Close: 50},
+		{Tradedate: "2024-01-03", Close: 55},
+	}
+
+	// Index returns do not depend on the dates the asset was traded on
+	saveProfits(writer, "IMOEX", index)
+	saveProfits(writer, "SBER", asset)
+	assert.NoError(t, writer.Close())
+
+	dates, profits, err := cache.GetProfits("IMOEX")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2024-01-02", "2024-01-03"}, dates)
+	assert.InDeltaSlice(t, []float64{0.1, 0.1}, profits, 1e-12)
+
+	dates, profits, err = cache.GetProfits("SBER")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2024-01-03"}, dates)
+	assert.InDeltaSlice(t, []float64{0.1}, profits, 1e-12)
+}
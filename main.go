@@ -38,19 +38,53 @@ func setupLogger(verbose bool) {
 func main() {
 	var verbose bool
 	var help bool
+	var cacheDSN string
+	var configFile string
 	var command hedging.Command
 
 	flag.StringVar(&command.Asset, "a", "", "base asset")
-	flag.StringVar(&command.Hedge, "i", "", "hedge/index asset")
-	flag.StringVar(&command.Report, "r", "", "report file")
+	flag.StringVar(&command.Hedge, "i", "", "hedge/index asset, several comma separated hedge instruments for cross hedge")
+	flag.StringVar(&command.Report, "r", "", "report file or DSN (same formats as for cache)")
 	flag.IntVar(&command.HistoryDepth, "d", 12, "history request depth")
+	flag.StringVar(&cacheDSN, "c", "", "cache file or DSN: path, sqlite://path, postgres://... or memory: (default is taken from "+hedging.CacheEnvVariable+", config file or user cache directory)")
+	flag.StringVar(&configFile, "config", "", "configuration file (default is config.json in user config directory)")
+	flag.IntVar(&command.RollingWindow, "window", 0, "rolling beta window in trading days (0 disables rolling beta)")
+	flag.IntVar(&command.RollingStep, "step", 1, "rolling beta step in trading days")
+	flag.StringVar(&command.Output, "o", "", "CSV file to export time series to")
+	flag.Float64Var(&command.Lambda, "lambda", 0, "EWMA decay factor of observations, e.g. 0.94 as in RiskMetrics (0 weights observations equally)")
+	flag.Float64Var(&command.HalfLife, "halflife", 0, "EWMA half-life of observations in trading days, alternative to -lambda")
+	flag.IntVar(&command.Lags, "lags", 0, "leads and lags of index returns for Dimson and Scholes-Williams betas (0 disables them)")
+	flag.StringVar(&command.Threshold, "threshold", "mean", "index return splitting downside and upside beta: number or mean")
+	flag.StringVar(&command.Returns, "returns", "simple", "kind of returns: simple or log")
+	flag.StringVar(&command.Prices, "prices", "close", "returns are calculated close-to-close, open-to-close (open) or settle-to-settle (settle)")
+	flag.StringVar(&command.Frequency, "freq", "daily", "sampling frequency of returns: daily, weekly or monthly")
+	flag.BoolVar(&command.Robust, "robust", false, "estimate beta and hedge ratio by Huber, Theil-Sen and LAD estimators as well")
+	flag.StringVar(&command.Outliers, "outliers", "none", "detection of outliers in returns: none, zscore or mad")
+	flag.Float64Var(&command.OutlierLimit, "outlier-limit", 0, "outlier detection limit in standard deviations (default 3 for zscore, 3.5 for mad)")
+	flag.StringVar(&command.OutlierAction, "outlier-action", "winsorize", "treatment of outliers: winsorize or remove")
+	flag.Float64Var(&command.PositionSize, "size", 0, "size of the hedged position to translate the hedge ratio into futures contracts")
+	flag.StringVar(&command.PositionUnits, "units", "shares", "units of the position size: shares or rub")
+	flag.StringVar(&command.Confidence, "confidence", "0.95,0.99", "comma separated confidence levels of variation margin estimates")
+	flag.StringVar(&command.Portfolio, "p", "", "portfolio file (CSV or YAML) with ticker and quantity or weight of positions")
+	flag.Float64Var(&command.TargetBeta, "target", 0, "target beta of the portfolio to reach by the hedge")
+	flag.BoolVar(&command.ErrorCorrection, "ecm", false, "estimate hedge ratio by the error correction model as well")
+	flag.IntVar(&command.JohansenLags, "johansen-lags", 1, "lagged differences of the VECM of Johansen cointegration test")
+	flag.BoolVar(&command.DCC, "dcc", false, "estimate dynamic hedge ratio by DCC-GARCH(1,1) model")
+	flag.IntVar(&command.BacktestWindow, "backtest-window", hedging.DefaultBacktestWindow, "trailing observations the backtest estimates the hedge ratio on")
+	flag.IntVar(&command.Rebalance, "rebalance", hedging.DefaultRebalancePeriod, "observations between rebalancings of the backtest hedge")
+	flag.BoolVar(&command.Kalman, "kalman", false, "estimate time-varying beta and hedge ratio by Kalman filter")
+	flag.Float64Var(&command.ProcessNoise, "process-noise", 0, "variance of the daily change of Kalman filter beta (0 estimates it by maximum likelihood)")
+	flag.Float64Var(&command.ObservationNoise, "observation-noise", 0, "variance of the residual return of Kalman filter (0 estimates it by maximum likelihood)")
+	flag.BoolVar(&command.Refresh, "refresh", false, "revalidate cached asset metadata")
+	flag.DurationVar(&command.MetadataTTL, "ttl", hedging.DefaultMetadataTTL, "how long cached asset metadata is fresh")
 	flag.BoolVar(&verbose, "v", false, "verbose logging")
 	flag.BoolVar(&help, "h", false, "show help")
 	flag.Parse()
 
 	if help {
 		fmt.Printf("Usage: %s [OPTIONS] command\n", os.Args[0])
-		fmt.Printf("\tpossible commands: beta, hedge\n")
+		fmt.Printf("\tpossible commands: beta, hedge, coint, backtest, basis, cache\n")
+		fmt.Printf("\tcache operations: migrate, stats, purge TICKER|--older-than DATE|DAYS, vacuum, export FILE, import FILE\n")
 		flag.PrintDefaults()
 		os.Exit(0)
 	}
@@ -66,7 +100,19 @@ func main() {
 
 	command.Asset = strings.ToUpper(command.Asset)
 	command.Hedge = strings.ToUpper(command.Hedge)
-	executor, error := hedging.CreateCommand(flag.Arg(0))
+	command.Args = flag.Args()[1:]
+
+	config, error := hedging.LoadConfig(configFile)
+	if error != nil {
+		log.Fatal(error)
+	}
+
+	cacheDSN, error = hedging.ResolveCacheDSN(cacheDSN, config)
+	if error != nil {
+		log.Fatal(error)
+	}
+
+	executor, error := hedging.CreateCommand(flag.Arg(0), cacheDSN)
 	if error != nil {
 		log.Fatal(error)
 	}